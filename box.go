@@ -0,0 +1,223 @@
+package vagrantexec
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+// Box encapsulates Vagrant box metadata.
+type Box struct {
+	Name         string
+	Provider     string
+	Version      string
+	Architecture string
+}
+
+// OutdatedBox is an installed box for which a newer version is available. The Version field holds the installed
+// version.
+type OutdatedBox struct {
+	Box
+	Latest string
+}
+
+// BoxList returns a list of all installed boxes.
+func (w wrapper) BoxList() ([]Box, error) {
+	return w.BoxListContext(context.Background())
+}
+
+// BoxListContext is like BoxList but includes a context.
+func (w wrapper) BoxListContext(ctx context.Context) (boxes []Box, err error) {
+	out, err := w.exec(ctx, "box", "list", "--machine-readable")
+	if err != nil {
+		return
+	}
+	boxInfo, err := parseMachineReadable(out)
+	if err != nil {
+		return
+	}
+
+	var box *Box
+	for _, entry := range boxInfo {
+		if entry.mType == "box-name" { // each box starts with its name
+			boxes = append(boxes, Box{Name: entry.data[0]})
+			box = &boxes[len(boxes)-1]
+			continue
+		}
+		if box == nil {
+			continue
+		}
+
+		switch entry.mType {
+		case "box-provider":
+			box.Provider = entry.data[0]
+		case "box-version":
+			box.Version = entry.data[0]
+		case "box-architecture":
+			box.Architecture = entry.data[0]
+		}
+	}
+	return
+}
+
+// BoxAdd downloads and installs a box with the given name, URL or file path. The provider, version and architecture are
+// used to select the box when they are set.
+func (w wrapper) BoxAdd(box Box) error {
+	return w.BoxAddContext(context.Background(), box)
+}
+
+// BoxAddContext is like BoxAdd but includes a context.
+func (w wrapper) BoxAddContext(ctx context.Context, box Box) error {
+	if len(box.Name) == 0 {
+		return errors.New("box must have a name")
+	}
+	cmdArgs := append([]string{"box", "add", box.Name, "--machine-readable"}, boxSelectorArgs(box)...)
+
+	w.logger.Infof("Adding vagrant box: %s", box.Name)
+	return w.execEvents(ctx, cmdArgs...)
+}
+
+// BoxRemove removes an installed box. All versions of the box are removed when no version is given.
+func (w wrapper) BoxRemove(box Box) error {
+	return w.BoxRemoveContext(context.Background(), box)
+}
+
+// BoxRemoveContext is like BoxRemove but includes a context.
+func (w wrapper) BoxRemoveContext(ctx context.Context, box Box) error {
+	if len(box.Name) == 0 {
+		return errors.New("box must have a name")
+	}
+	cmdArgs := append([]string{"box", "remove", box.Name, "--machine-readable"}, boxSelectorArgs(box)...)
+	if len(box.Version) == 0 {
+		cmdArgs = append(cmdArgs, "--all")
+	}
+
+	w.logger.Infof("Removing vagrant box: %s", box.Name)
+	return w.execEvents(ctx, cmdArgs...)
+}
+
+// BoxUpdate downloads the latest version of a box, optionally limited to a single provider and architecture. The boxes
+// used by the machines in the Vagrantfile are updated when the box has no name. Vagrant always updates to the latest
+// version, so an error is returned when the box has a version.
+func (w wrapper) BoxUpdate(box Box) error {
+	return w.BoxUpdateContext(context.Background(), box)
+}
+
+// BoxUpdateContext is like BoxUpdate but includes a context.
+func (w wrapper) BoxUpdateContext(ctx context.Context, box Box) error {
+	if len(box.Version) > 0 {
+		return errors.New("box update cannot target a specific version")
+	}
+
+	cmdArgs := []string{"box", "update", "--machine-readable"}
+	if len(box.Name) > 0 {
+		cmdArgs = append(cmdArgs, "--box", box.Name)
+	}
+	if len(box.Provider) > 0 {
+		cmdArgs = append(cmdArgs, "--provider", box.Provider)
+	}
+	if len(box.Architecture) > 0 {
+		cmdArgs = append(cmdArgs, "--architecture", box.Architecture)
+	}
+
+	w.logger.Info("Updating vagrant boxes")
+	return w.execEvents(ctx, cmdArgs...)
+}
+
+// BoxOutdated returns a list of all installed boxes for which a newer version is available.
+func (w wrapper) BoxOutdated() ([]OutdatedBox, error) {
+	return w.BoxOutdatedContext(context.Background())
+}
+
+// BoxOutdatedContext is like BoxOutdated but includes a context.
+func (w wrapper) BoxOutdatedContext(ctx context.Context) (boxes []OutdatedBox, err error) {
+	out, err := w.exec(ctx, "box", "outdated", "--global", "--machine-readable")
+	if err != nil {
+		return
+	}
+	boxInfo, err := parseMachineReadable(out)
+	if err != nil {
+		return
+	}
+	outdatedExtractor := regexp.MustCompile(`^\* '(.+)' for '(.+)' is outdated! Current: (.+)\. Latest: (.+)$`)
+	for _, entry := range boxInfo {
+		if entry.mType != "ui" || len(entry.data) < 2 { // outdated boxes are only reported as human-readable messages
+			continue
+		}
+
+		if ms := outdatedExtractor.FindStringSubmatch(entry.data[1]); ms != nil {
+			boxes = append(boxes, OutdatedBox{
+				Box: Box{
+					Name:     ms[1],
+					Provider: ms[2],
+					Version:  ms[3],
+				},
+				Latest: ms[4],
+			})
+		}
+	}
+	return
+}
+
+// BoxPrune removes old versions of installed boxes. Boxes still in use by a machine are kept.
+func (w wrapper) BoxPrune() error {
+	return w.BoxPruneContext(context.Background())
+}
+
+// BoxPruneContext is like BoxPrune but includes a context.
+func (w wrapper) BoxPruneContext(ctx context.Context) error {
+	w.logger.Info("Pruning vagrant boxes")
+	return w.execEvents(ctx, "box", "prune", "--force", "--keep-active-boxes", "--machine-readable")
+}
+
+// IsBoxInstalled checks if a box has already been installed. The provider, version and architecture are only compared
+// when they are set. It will return an error if the box arg has no name or the underlying list operation fails.
+func (w wrapper) IsBoxInstalled(box Box) (bool, error) {
+	return w.IsBoxInstalledContext(context.Background(), box)
+}
+
+// IsBoxInstalledContext is like IsBoxInstalled but includes a context.
+func (w wrapper) IsBoxInstalledContext(ctx context.Context, box Box) (installed bool, err error) {
+	if len(box.Name) == 0 {
+		err = errors.New("box must have a name")
+		return
+	}
+
+	installedBoxes, err := w.BoxListContext(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, b := range installedBoxes {
+		if b.Name != box.Name {
+			continue
+		}
+		if len(box.Provider) > 0 && b.Provider != box.Provider {
+			continue
+		}
+		if len(box.Version) > 0 && b.Version != box.Version {
+			continue
+		}
+		if len(box.Architecture) > 0 && b.Architecture != box.Architecture {
+			continue
+		}
+
+		installed = true
+		break
+	}
+	return
+}
+
+// boxSelectorArgs returns the flags that select a specific provider, version and architecture of a box.
+func boxSelectorArgs(box Box) (args []string) {
+	if len(box.Provider) > 0 {
+		args = append(args, "--provider", box.Provider)
+	}
+	if len(box.Version) > 0 {
+		args = append(args, "--box-version", box.Version)
+	}
+	if len(box.Architecture) > 0 {
+		args = append(args, "--architecture", box.Architecture)
+	}
+	return
+}
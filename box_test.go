@@ -0,0 +1,259 @@
+package vagrantexec
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoxList(t *testing.T) {
+	mockBoxList := mockedWrapperFn([]string{"box", "list", "--machine-readable"})
+
+	t.Run("with_boxes", func(t *testing.T) {
+		w := mockBoxList(ioutil.ReadFile("testdata/box-list"))
+
+		actual, err := w.BoxList()
+		require.NoError(t, err)
+
+		expected := []Box{
+			{
+				Name:     "hashicorp/bionic64",
+				Provider: "virtualbox",
+				Version:  "1.0.282",
+			},
+			{
+				Name:         "generic/ubuntu2004",
+				Provider:     "libvirt",
+				Version:      "4.2.16",
+				Architecture: "amd64",
+			},
+		}
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("no_boxes", func(t *testing.T) {
+		w := mockBoxList(ioutil.ReadFile("testdata/box-list-none"))
+
+		actual, err := w.BoxList()
+		require.NoError(t, err)
+		assert.Empty(t, actual)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockBoxList(nil, errors.New("runner error"))
+
+		_, err := w.BoxList()
+		assert.Error(t, err)
+	})
+}
+
+func TestBoxAdd(t *testing.T) {
+	mockBoxAdd := mockedWrapperFn([]string{"box", "add", "hashicorp/bionic64", "--machine-readable"})
+
+	box := Box{Name: "hashicorp/bionic64"}
+	t.Run("success", func(t *testing.T) {
+		w := mockBoxAdd(nil, nil)
+		assert.NoError(t, w.BoxAdd(box))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockBoxAdd(nil, errors.New("runner error"))
+		assert.Error(t, w.BoxAdd(box))
+	})
+
+	t.Run("no_name", func(t *testing.T) {
+		w := mockBoxAdd(nil, nil)
+		assert.EqualError(t, w.BoxAdd(Box{}), "box must have a name")
+	})
+
+	t.Run("selectors", func(t *testing.T) {
+		mockBoxAdd := mockedWrapperFn([]string{
+			"box", "add", "generic/ubuntu2004", "--machine-readable",
+			"--provider", "libvirt", "--box-version", "4.2.16", "--architecture", "amd64",
+		})
+		w := mockBoxAdd(nil, nil)
+
+		box := Box{Name: "generic/ubuntu2004", Provider: "libvirt", Version: "4.2.16", Architecture: "amd64"}
+		assert.NoError(t, w.BoxAdd(box))
+	})
+}
+
+func TestBoxRemove(t *testing.T) {
+	mockBoxRemove := mockedWrapperFn([]string{"box", "remove", "hashicorp/bionic64", "--machine-readable", "--all"})
+
+	box := Box{Name: "hashicorp/bionic64"}
+	t.Run("all_versions", func(t *testing.T) {
+		w := mockBoxRemove(nil, nil)
+		assert.NoError(t, w.BoxRemove(box))
+	})
+
+	t.Run("with_version", func(t *testing.T) {
+		mockBoxRemove := mockedWrapperFn([]string{
+			"box", "remove", "hashicorp/bionic64", "--machine-readable", "--provider", "virtualbox", "--box-version", "1.0.282",
+		})
+		w := mockBoxRemove(nil, nil)
+
+		box := Box{Name: "hashicorp/bionic64", Provider: "virtualbox", Version: "1.0.282"}
+		assert.NoError(t, w.BoxRemove(box))
+	})
+
+	t.Run("no_name", func(t *testing.T) {
+		w := mockBoxRemove(nil, nil)
+		assert.EqualError(t, w.BoxRemove(Box{}), "box must have a name")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockBoxRemove(nil, errors.New("runner error"))
+		assert.Error(t, w.BoxRemove(box))
+	})
+}
+
+func TestBoxUpdate(t *testing.T) {
+	mockBoxUpdate := mockedWrapperFn([]string{"box", "update", "--machine-readable"})
+
+	t.Run("environment", func(t *testing.T) {
+		w := mockBoxUpdate(nil, nil)
+		assert.NoError(t, w.BoxUpdate(Box{}))
+	})
+
+	t.Run("specific_box", func(t *testing.T) {
+		mockBoxUpdate := mockedWrapperFn([]string{
+			"box", "update", "--machine-readable", "--box", "hashicorp/bionic64", "--provider", "virtualbox",
+		})
+		w := mockBoxUpdate(nil, nil)
+		assert.NoError(t, w.BoxUpdate(Box{Name: "hashicorp/bionic64", Provider: "virtualbox"}))
+	})
+
+	t.Run("architecture", func(t *testing.T) {
+		mockBoxUpdate := mockedWrapperFn([]string{
+			"box", "update", "--machine-readable", "--box", "hashicorp/bionic64", "--architecture", "arm64",
+		})
+		w := mockBoxUpdate(nil, nil)
+		assert.NoError(t, w.BoxUpdate(Box{Name: "hashicorp/bionic64", Architecture: "arm64"}))
+	})
+
+	t.Run("version", func(t *testing.T) {
+		w := mockBoxUpdate(nil, nil)
+		err := w.BoxUpdate(Box{Name: "hashicorp/bionic64", Version: "1.0.282"})
+		assert.EqualError(t, err, "box update cannot target a specific version")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockBoxUpdate(nil, errors.New("runner error"))
+		assert.Error(t, w.BoxUpdate(Box{}))
+	})
+}
+
+func TestBoxOutdated(t *testing.T) {
+	mockBoxOutdated := mockedWrapperFn([]string{"box", "outdated", "--global", "--machine-readable"})
+
+	t.Run("success", func(t *testing.T) {
+		w := mockBoxOutdated(ioutil.ReadFile("testdata/box-outdated"))
+
+		actual, err := w.BoxOutdated()
+		require.NoError(t, err)
+
+		expected := []OutdatedBox{
+			{
+				Box: Box{
+					Name:     "hashicorp/bionic64",
+					Provider: "virtualbox",
+					Version:  "1.0.282",
+				},
+				Latest: "1.0.284",
+			},
+		}
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockBoxOutdated(nil, errors.New("runner error"))
+
+		_, err := w.BoxOutdated()
+		assert.Error(t, err)
+	})
+}
+
+func TestBoxPrune(t *testing.T) {
+	mockBoxPrune := mockedWrapperFn([]string{"box", "prune", "--force", "--keep-active-boxes", "--machine-readable"})
+
+	t.Run("success", func(t *testing.T) {
+		w := mockBoxPrune(nil, nil)
+		assert.NoError(t, w.BoxPrune())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockBoxPrune(nil, errors.New("runner error"))
+		assert.Error(t, w.BoxPrune())
+	})
+}
+
+func TestIsBoxInstalled(t *testing.T) {
+	mockBoxList := mockedWrapperFn([]string{"box", "list", "--machine-readable"})
+	w := mockBoxList(ioutil.ReadFile("testdata/box-list"))
+
+	testcases := []struct {
+		name     string
+		box      Box
+		expected bool
+	}{
+		{
+			"name_only",
+			Box{Name: "hashicorp/bionic64"},
+			true,
+		},
+		{
+			"with_provider_and_version",
+			Box{Name: "hashicorp/bionic64", Provider: "virtualbox", Version: "1.0.282"},
+			true,
+		},
+		{
+			"wrong_provider",
+			Box{Name: "hashicorp/bionic64", Provider: "libvirt"},
+			false,
+		},
+		{
+			"wrong_version",
+			Box{Name: "hashicorp/bionic64", Version: "1.0.0"},
+			false,
+		},
+		{
+			"with_architecture",
+			Box{Name: "generic/ubuntu2004", Architecture: "amd64"},
+			true,
+		},
+		{
+			"wrong_architecture",
+			Box{Name: "generic/ubuntu2004", Architecture: "arm64"},
+			false,
+		},
+		{
+			"not_installed",
+			Box{Name: "other-box"},
+			false,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := w.IsBoxInstalled(tc.box)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+
+	t.Run("no_name", func(t *testing.T) {
+		_, err := w.IsBoxInstalled(Box{})
+		assert.Error(t, err)
+	})
+
+	t.Run("list_error", func(t *testing.T) {
+		w := mockBoxList(nil, errors.New("runner error"))
+
+		_, err := w.IsBoxInstalled(Box{Name: "doesnt-matter"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,32 @@
+package command
+
+import "fmt"
+
+// CancelError is created whenever a command is terminated because its context was cancelled or timed out.
+type CancelError struct {
+	msg string
+	err error
+}
+
+func (e CancelError) Error() string {
+	return e.msg
+}
+
+// Cause returns the context error that triggered the cancellation.
+func (e CancelError) Cause() error {
+	return e.err
+}
+
+// Unwrap returns the context error so it can be matched with errors.Is.
+func (e CancelError) Unwrap() error {
+	return e.err
+}
+
+// NewCancelError creates a new CancelError with a descriptive message. Custom Runner implementations should use it to
+// report commands that were terminated because their context was done.
+func NewCancelError(cmd string, err error) CancelError {
+	return CancelError{
+		msg: fmt.Sprintf("%s was cancelled: %s", cmd, err),
+		err: err,
+	}
+}
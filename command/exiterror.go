@@ -20,8 +20,9 @@ func (e ExitError) ExitStatus() int {
 	return e.exitStatus
 }
 
-// newExistError creates a new ExitError with a descriptive message.
-func newExitError(cmd string, exitStatus int, msg string) ExitError {
+// NewExitError creates a new ExitError with a descriptive message. Custom Runner implementations should use it to
+// report commands that exit with a non-zero status.
+func NewExitError(cmd string, exitStatus int, msg string) ExitError {
 	return ExitError{
 		msg:        fmt.Sprintf("%s exited with status %d: %s", cmd, exitStatus, strings.TrimSpace(msg)),
 		exitStatus: exitStatus,
@@ -0,0 +1,53 @@
+package command
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// LineWriter is an io.Writer that invokes a callback for every complete line written to it. It is meant to be used as
+// a Streams sink when output should be processed line by line while a command is running.
+type LineWriter struct {
+	fn  func(line string)
+	buf []byte
+	mu  sync.Mutex
+}
+
+// NewLineWriter creates a LineWriter that passes each line, stripped of its line ending, to fn.
+func NewLineWriter(fn func(line string)) *LineWriter {
+	return &LineWriter{fn: fn}
+}
+
+// Write buffers p and invokes the callback for every line it completes.
+func (lw *LineWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		lw.emit(lw.buf[:i])
+		lw.buf = lw.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush invokes the callback with any trailing output that was not terminated by a newline.
+func (lw *LineWriter) Flush() error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	if len(lw.buf) > 0 {
+		lw.emit(lw.buf)
+		lw.buf = nil
+	}
+	return nil
+}
+
+func (lw *LineWriter) emit(line []byte) {
+	lw.fn(strings.TrimSuffix(string(line), "\r"))
+}
@@ -0,0 +1,26 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	lw := NewLineWriter(func(line string) { lines = append(lines, line) })
+
+	for _, chunk := range []string{"first li", "ne\nsecond line\r\nthi", "rd", " line"} {
+		n, err := lw.Write([]byte(chunk))
+		require.NoError(t, err)
+		assert.Equal(t, len(chunk), n)
+	}
+	assert.Equal(t, []string{"first line", "second line"}, lines)
+
+	require.NoError(t, lw.Flush())
+	assert.Equal(t, []string{"first line", "second line", "third line"}, lines)
+
+	require.NoError(t, lw.Flush())
+	assert.Len(t, lines, 3, "flush should not emit empty lines")
+}
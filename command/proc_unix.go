@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package command
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup places the command in its own process group so its children can be signalled together. This moves
+// the command out of the terminal's foreground process group, so it is only done for commands that can be cancelled.
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the command along with every process in its group.
+func killProcessGroup(c *exec.Cmd) {
+	if c.Process == nil {
+		return
+	}
+	_ = syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+// +build !windows
+
+package command
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessGroup(t *testing.T) {
+	// pgid returns the process group of a shell started by the runner
+	pgid := func(ctx context.Context) int {
+		out, err := ShellRunner{}.ExecuteContext(ctx, "sh", "-c", "ps -o pgid= -p $$")
+		require.NoError(t, err)
+
+		id, err := strconv.Atoi(strings.TrimSpace(string(out)))
+		require.NoError(t, err)
+		return id
+	}
+
+	t.Run("not_cancellable", func(t *testing.T) {
+		assert.Equal(t, syscall.Getpgrp(), pgid(context.Background()))
+	})
+
+	t.Run("cancellable", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		assert.NotEqual(t, syscall.Getpgrp(), pgid(ctx))
+	})
+}
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package command
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup is a no-op on windows; the process tree is resolved when it is killed.
+func setProcessGroup(c *exec.Cmd) {}
+
+// killProcessGroup kills the command along with every process it spawned.
+func killProcessGroup(c *exec.Cmd) {
+	if c.Process == nil {
+		return
+	}
+	_ = exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(c.Process.Pid)).Run()
+}
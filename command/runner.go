@@ -3,18 +3,34 @@ package command
 
 import (
 	"bytes"
+	"context"
+	"io"
+	"os"
 	"os/exec"
 )
 
 // Runner provides an interface for running external commands.
 type Runner interface {
 	Execute(cmd string, args ...string) ([]byte, error)
+	ExecuteContext(ctx context.Context, cmd string, args ...string) ([]byte, error)
+	Stream(ctx context.Context, streams Streams, cmd string, args ...string) error
+}
+
+// Streams holds the reader that provides the input of a command and the writers that receive its output while it is
+// running. A nil Stdin reads from the null device and nil writers are discarded.
+type Streams struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
 }
 
 // ShellRunner provides provides a simplified interface to exec.Command making it easier to process output and errors.
 type ShellRunner struct {
 	// Dir is the directory where the commands will be executed.
 	Dir string
+	// Env contains additional environment variables in the form "KEY=value". They are added to the environment of the
+	// current process, overriding any variable with the same key.
+	Env []string
 }
 
 // Execute invokes a shell command with any number of arguments and returns standard output.
@@ -22,19 +38,101 @@ type ShellRunner struct {
 // If the command starts but does not complete successfully, an ExitError will be returned with output from standard
 // error. Any other error will result in a panic.
 func (r ShellRunner) Execute(cmd string, args ...string) ([]byte, error) {
+	return r.ExecuteContext(context.Background(), cmd, args...)
+}
+
+// ExecuteContext behaves like Execute but terminates the command, along with any processes it spawned, when the
+// context is done before the command completes. A CancelError is returned in that case.
+//
+// When the context can be cancelled, the command runs in its own process group on unix so that it can be terminated
+// with all of its children. Signals sent by the terminal, e.g. on Ctrl-C, are then no longer delivered to the command:
+// callers should cancel the context instead, for instance with signal.NotifyContext. Commands run with a context that
+// is never done, like context.Background, stay in the process group of the caller.
+func (r ShellRunner) ExecuteContext(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+	var stdout bytes.Buffer
+	err := r.Stream(ctx, Streams{Stdout: &stdout}, cmd, args...)
+
+	return stdout.Bytes(), err
+}
+
+// Stream invokes a shell command with any number of arguments and copies standard output and error to the given
+// streams as soon as it is produced. Standard input is copied from streams.Stdin until it is exhausted, the command
+// exits or the context is done, so a reader that blocks, like a pipe or a network connection, never keeps Stream from
+// returning. Errors are reported the same way as ExecuteContext.
+func (r ShellRunner) Stream(ctx context.Context, streams Streams, cmd string, args ...string) error {
 	c := exec.Command(cmd, args...)
 	c.Dir = r.Dir
+	if len(r.Env) > 0 {
+		c.Env = append(os.Environ(), r.Env...)
+	}
+	if ctx.Done() != nil {
+		setProcessGroup(c)
+	}
 
-	var stdout, stderr bytes.Buffer
-	c.Stdout = &stdout
+	var stderr bytes.Buffer
+	c.Stdout = streams.Stdout
 	c.Stderr = &stderr
-	err := c.Run()
+	if streams.Stderr != nil {
+		c.Stderr = io.MultiWriter(&stderr, streams.Stderr)
+	}
+	err := run(ctx, c, streams.Stdin)
 
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return NewCancelError(cmd, ctxErr)
+		}
 		if ee, ok := err.(*exec.ExitError); ok {
-			err = newExitError(cmd, ee.ExitCode(), string(stderr.Bytes()))
+			err = NewExitError(cmd, ee.ExitCode(), string(stderr.Bytes()))
 		}
 	}
 
-	return stdout.Bytes(), err
+	return err
+}
+
+// run starts the command and waits for it to complete, killing its process group if the context is done first.
+//
+// Unless stdin is a file, it is copied to the command through a pipe owned by run rather than by exec.Cmd, whose Wait
+// also waits for the copy to finish and would block for as long as the reader does.
+func run(ctx context.Context, c *exec.Cmd, stdin io.Reader) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var pr, pw *os.File
+	if f, ok := stdin.(*os.File); ok {
+		c.Stdin = f
+	} else if stdin != nil {
+		var err error
+		if pr, pw, err = os.Pipe(); err != nil {
+			return err
+		}
+		defer pw.Close() // unblocks the copy once the command has exited
+		c.Stdin = pr
+	}
+	err := c.Start()
+	if pr != nil {
+		pr.Close() // the command holds its own copy
+	}
+	if err != nil {
+		return err
+	}
+
+	if pw != nil {
+		go func() {
+			_, _ = io.Copy(pw, stdin)
+			pw.Close()
+		}()
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(c)
+		case <-done:
+		}
+	}()
+
+	return c.Wait()
 }
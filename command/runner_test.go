@@ -1,8 +1,14 @@
 package command
 
 import (
+	"bytes"
+	"context"
+	"io"
+	"os"
 	"os/exec"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -25,6 +31,19 @@ func TestExecute(t *testing.T) {
 		assert.Equal(t, "/usr\n", string(out))
 	})
 
+	t.Run("with_env", func(t *testing.T) {
+		require.NoError(t, os.Setenv("VAGRANT_EXEC_INHERITED", "inherited"))
+		require.NoError(t, os.Setenv("VAGRANT_EXEC_OVERRIDDEN", "original"))
+		defer os.Unsetenv("VAGRANT_EXEC_INHERITED")
+		defer os.Unsetenv("VAGRANT_EXEC_OVERRIDDEN")
+
+		sr := ShellRunner{Env: []string{"VAGRANT_EXEC_OVERRIDDEN=override", "VAGRANT_EXEC_ADDED=added"}}
+		out, err := sr.Execute("sh", "-c", "echo $VAGRANT_EXEC_INHERITED $VAGRANT_EXEC_OVERRIDDEN $VAGRANT_EXEC_ADDED")
+
+		require.NoError(t, err)
+		assert.Equal(t, "inherited override added\n", string(out))
+	})
+
 	t.Run("exit_error", func(t *testing.T) {
 		sr := ShellRunner{}
 		_, err := sr.Execute("sh", "-c", "echo 'actual err msg' >&2 && exit 64")
@@ -43,3 +62,126 @@ func TestExecute(t *testing.T) {
 		assert.IsType(t, new(exec.Error), err)
 	})
 }
+
+func TestExecuteContext(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		sr := ShellRunner{}
+		out, err := sr.ExecuteContext(context.Background(), "echo", "hello world")
+
+		require.NoError(t, err)
+		assert.Equal(t, "hello world\n", string(out))
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		sr := ShellRunner{}
+		start := time.Now()
+		_, err := sr.ExecuteContext(ctx, "sh", "-c", "sleep 10 & wait")
+		require.IsType(t, CancelError{}, err)
+
+		ce := err.(CancelError)
+		assert.Equal(t, context.DeadlineExceeded, ce.Cause())
+		assert.Equal(t, "sh was cancelled: context deadline exceeded", ce.Error())
+		assert.True(t, time.Since(start) < 5*time.Second, "process tree was not killed")
+	})
+
+	t.Run("already_cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		sr := ShellRunner{}
+		_, err := sr.ExecuteContext(ctx, "echo", "never")
+		require.IsType(t, CancelError{}, err)
+		assert.Equal(t, context.Canceled, err.(CancelError).Cause())
+	})
+}
+
+func TestStream(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		var stdout, stderr []string
+		streams := Streams{
+			Stdout: NewLineWriter(func(line string) { stdout = append(stdout, line) }),
+			Stderr: NewLineWriter(func(line string) { stderr = append(stderr, line) }),
+		}
+
+		sr := ShellRunner{}
+		err := sr.Stream(context.Background(), streams, "sh", "-c", "echo out-1; echo err-1 >&2; echo out-2")
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"out-1", "out-2"}, stdout)
+		assert.Equal(t, []string{"err-1"}, stderr)
+	})
+
+	t.Run("while_running", func(t *testing.T) {
+		var received time.Time
+		streams := Streams{
+			Stdout: NewLineWriter(func(line string) { received = time.Now() }),
+		}
+
+		sr := ShellRunner{}
+		err := sr.Stream(context.Background(), streams, "sh", "-c", "echo first; sleep 0.5")
+		finished := time.Now()
+
+		require.NoError(t, err)
+		assert.True(t, finished.Sub(received) > 250*time.Millisecond, "output was not streamed")
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		var stdout bytes.Buffer
+		streams := Streams{
+			Stdin:  strings.NewReader("line-1\nline-2\n"),
+			Stdout: &stdout,
+		}
+
+		sr := ShellRunner{}
+		err := sr.Stream(context.Background(), streams, "wc", "-l")
+
+		require.NoError(t, err)
+		assert.Equal(t, "2", strings.TrimSpace(stdout.String()))
+	})
+
+	t.Run("blocking_stdin_cancelled", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		defer pw.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		sr := ShellRunner{}
+		start := time.Now()
+		err := sr.Stream(ctx, Streams{Stdin: pr}, "sleep", "30")
+
+		require.IsType(t, CancelError{}, err)
+		assert.True(t, time.Since(start) < 5*time.Second, "blocked on the stdin reader")
+	})
+
+	t.Run("blocking_stdin_unread", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		defer pw.Close()
+
+		sr := ShellRunner{}
+		assert.NoError(t, sr.Stream(context.Background(), Streams{Stdin: pr}, "true"))
+	})
+
+	t.Run("nil_streams", func(t *testing.T) {
+		sr := ShellRunner{}
+		assert.NoError(t, sr.Stream(context.Background(), Streams{}, "echo", "discarded"))
+	})
+
+	t.Run("exit_error", func(t *testing.T) {
+		var stderr []string
+		streams := Streams{
+			Stderr: NewLineWriter(func(line string) { stderr = append(stderr, line) }),
+		}
+
+		sr := ShellRunner{}
+		err := sr.Stream(context.Background(), streams, "sh", "-c", "echo 'actual err msg' >&2 && exit 3")
+		require.IsType(t, ExitError{}, err)
+
+		assert.Equal(t, 3, err.(ExitError).ExitStatus())
+		assert.Equal(t, "sh exited with status 3: actual err msg", err.Error())
+		assert.Equal(t, []string{"actual err msg"}, stderr)
+	})
+}
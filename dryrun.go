@@ -0,0 +1,180 @@
+package vagrantexec
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dominodatalab/vagrant-exec/command"
+)
+
+// readOnlyCommands lists the vagrant subcommands that are still run in dry-run mode because they do not change any
+// machine, box or plugin.
+var readOnlyCommands = [][]string{
+	{"status"},
+	{"global-status"},
+	{"version"},
+	{"ssh-config"},
+	{"port"},
+	{"plugin", "list"},
+	{"box", "list"},
+	{"box", "outdated"},
+	{"snapshot", "list"},
+}
+
+// mutatingFlags lists the flags that make an otherwise read-only subcommand change state, e.g. `global-status --prune`
+// removes invalid entries from the machine index.
+var mutatingFlags = map[string]bool{
+	"--prune": true,
+}
+
+// PlannedCommand is a command that would have been run by a wrapper in dry-run mode.
+type PlannedCommand struct {
+	Executable string
+	Args       []string
+	// Env contains the environment variables added to the command, in the form "KEY=value".
+	Env []string
+	// Dir is the directory the command would run in. It is empty when the runner is not a command.ShellRunner.
+	Dir string
+	// Machines are the names of the machines the command would act on. Patterns and empty targets, which act on every
+	// machine, are resolved with `vagrant status`. It is empty for commands that do not target machines.
+	Machines []string
+}
+
+// String returns the command line, prefixed by its additional environment variables. Arguments containing characters
+// that are special to a POSIX shell are single-quoted, so the line can be pasted into a shell to replay the command.
+func (c PlannedCommand) String() string {
+	parts := make([]string, 0, len(c.Env)+len(c.Args)+1)
+	for _, kv := range c.Env {
+		parts = append(parts, quoteArg(kv))
+	}
+	parts = append(parts, quoteArg(c.Executable))
+	for _, arg := range c.Args {
+		parts = append(parts, quoteArg(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+// DryRun records the commands that a wrapper configured with WithDryRun would run. It is safe for concurrent use.
+type DryRun struct {
+	mu       sync.Mutex
+	commands []PlannedCommand
+}
+
+// Commands returns the recorded commands in the order they were planned.
+func (d *DryRun) Commands() []PlannedCommand {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]PlannedCommand(nil), d.commands...)
+}
+
+// record adds a command to the plan.
+func (d *DryRun) record(c PlannedCommand) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.commands = append(d.commands, c)
+}
+
+// skip records a command and returns true when the wrapper is in dry-run mode and the command is not read-only.
+func (w wrapper) skip(executable string, args []string) bool {
+	if w.dryRun == nil || (executable == w.executable && isReadOnly(args)) {
+		return false
+	}
+
+	planned := PlannedCommand{
+		Executable: executable,
+		Args:       append([]string(nil), args...),
+		Machines:   w.machines,
+	}
+	if r, ok := w.runner.(command.ShellRunner); ok {
+		planned.Env = append([]string(nil), r.Env...)
+		planned.Dir = r.Dir
+	}
+	w.dryRun.record(planned)
+
+	w.logger.Infof("Dry run, skipping command [%s]", planned)
+	return true
+}
+
+// planTargets returns a copy of the wrapper that records the machines matched by the targets in its dry-run plan.
+// Patterns and empty targets are resolved by querying the status of the machines, which is still run in dry-run mode.
+func (w wrapper) planTargets(ctx context.Context, targets []string) (wrapper, error) {
+	if w.dryRun == nil {
+		return w, nil
+	}
+
+	resolve := len(targets) == 0
+	for _, t := range targets {
+		if isTargetPattern(t) {
+			resolve = true
+		}
+	}
+	if !resolve {
+		w.machines = append([]string(nil), targets...)
+		return w, nil
+	}
+
+	statuses, err := w.StatusContext(ctx, targets...)
+	if err != nil {
+		return w, err
+	}
+	w.machines = make([]string, 0, len(statuses))
+	for _, s := range statuses {
+		w.machines = append(w.machines, s.Name)
+	}
+	sort.Strings(w.machines) // statuses are not reported in a stable order
+	return w, nil
+}
+
+// isTargetPattern returns true if the target is a regular expression created with TargetPattern.
+func isTargetPattern(target string) bool {
+	return len(target) >= 2 && strings.HasPrefix(target, "/") && strings.HasSuffix(target, "/")
+}
+
+// isReadOnly returns true if the arguments start with any of the read-only subcommands and contain none of the
+// mutating flags.
+func isReadOnly(args []string) bool {
+	for _, arg := range args {
+		if mutatingFlags[arg] {
+			return false
+		}
+	}
+
+	for _, subcommand := range readOnlyCommands {
+		if len(args) < len(subcommand) {
+			continue
+		}
+
+		match := true
+		for i, s := range subcommand {
+			if args[i] != s {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteArg single-quotes an argument when it would otherwise be split, expanded or misread by a POSIX shell.
+func quoteArg(arg string) string {
+	if len(arg) > 0 && strings.IndexFunc(arg, isUnsafeShellRune) < 0 {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// isUnsafeShellRune returns true for any character that a shell may treat specially.
+func isUnsafeShellRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	}
+	return !strings.ContainsRune("@%+=:,./_-", r)
+}
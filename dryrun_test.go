@@ -0,0 +1,167 @@
+package vagrantexec
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRun(t *testing.T) {
+	t.Run("mutating_commands", func(t *testing.T) {
+		runner := mockEnsure("srv-1=running", "srv-2=poweroff")
+		plan := new(DryRun)
+
+		w := mockedWrapper(runner)
+		w.dryRun = plan
+
+		actions, err := w.EnsureAbsent()
+		require.NoError(t, err)
+		assert.Len(t, actions, 2)
+		require.NoError(t, w.PluginInstall(Plugin{Name: "vagrant-disksize"}))
+
+		expected := []PlannedCommand{
+			{Executable: "vagrant", Args: []string{"destroy", "--force", "--machine-readable", "srv-1"}, Machines: []string{"srv-1"}},
+			{Executable: "vagrant", Args: []string{"destroy", "--force", "--machine-readable", "srv-2"}, Machines: []string{"srv-2"}},
+			{Executable: "vagrant", Args: []string{"plugin", "install", "vagrant-disksize"}},
+		}
+		assert.Equal(t, expected, plan.Commands())
+		runner.AssertNumberOfCalls(t, "Execute", 1) // only the status was queried
+	})
+
+	t.Run("read_only_commands", func(t *testing.T) {
+		runner := new(mockRunner)
+		runner.On("Execute", "vagrant", []string{"version", "--machine-readable"}).Return([]byte("1563212501,,version-installed,2.2.5"), nil)
+		runner.On("Execute", "vagrant", []string{"plugin", "list", "--machine-readable"}).Return(nil, nil)
+		plan := new(DryRun)
+
+		w := mockedWrapper(runner)
+		w.dryRun = plan
+
+		version, err := w.Version()
+		require.NoError(t, err)
+		assert.Equal(t, "2.2.5", version)
+		_, err = w.PluginList()
+		require.NoError(t, err)
+
+		assert.Empty(t, plan.Commands())
+		runner.AssertExpectations(t)
+	})
+
+	t.Run("untargeted", func(t *testing.T) {
+		runner := mockEnsure("srv-1=running", "srv-2=poweroff")
+		plan := new(DryRun)
+
+		w := mockedWrapper(runner)
+		w.dryRun = plan
+
+		require.NoError(t, w.Destroy())
+
+		expected := []PlannedCommand{
+			{Executable: "vagrant", Args: []string{"destroy", "--force", "--machine-readable"}, Machines: []string{"srv-1", "srv-2"}},
+		}
+		assert.Equal(t, expected, plan.Commands())
+	})
+
+	t.Run("pattern_targeted", func(t *testing.T) {
+		pattern := TargetPattern("srv-.*")
+		runner := new(mockRunner)
+		runner.On("Execute", "vagrant", []string{"status", "--machine-readable", pattern}).
+			Return(append(statusOutput("srv-1", "running"), statusOutput("srv-2", "running")...), nil)
+		plan := new(DryRun)
+
+		w := mockedWrapper(runner)
+		w.dryRun = plan
+
+		require.NoError(t, w.Halt(pattern))
+
+		expected := []PlannedCommand{
+			{Executable: "vagrant", Args: []string{"halt", "--machine-readable", pattern}, Machines: []string{"srv-1", "srv-2"}},
+		}
+		assert.Equal(t, expected, plan.Commands())
+		runner.AssertExpectations(t)
+	})
+
+	t.Run("status_error", func(t *testing.T) {
+		runner := new(mockRunner)
+		runner.On("Execute", "vagrant", []string{"status", "--machine-readable"}).Return(nil, errors.New("runner error"))
+		plan := new(DryRun)
+
+		w := mockedWrapper(runner)
+		w.dryRun = plan
+
+		assert.EqualError(t, w.SnapshotSave("before-upgrade", ""), "runner error")
+		assert.Empty(t, plan.Commands())
+	})
+
+	t.Run("global_status_prune", func(t *testing.T) {
+		runner := new(mockRunner)
+		runner.On("Execute", "vagrant", []string{"global-status", "--machine-readable"}).Return(nil, nil)
+		plan := new(DryRun)
+
+		w := mockedWrapper(runner)
+		w.dryRun = plan
+
+		_, err := w.GlobalStatus(false)
+		require.NoError(t, err)
+		_, err = w.GlobalStatus(true)
+		require.NoError(t, err)
+
+		expected := []PlannedCommand{
+			{Executable: "vagrant", Args: []string{"global-status", "--machine-readable", "--prune"}},
+		}
+		assert.Equal(t, expected, plan.Commands())
+		runner.AssertExpectations(t)
+		runner.AssertNumberOfCalls(t, "Execute", 1)
+	})
+
+	t.Run("shell_runner", func(t *testing.T) {
+		plan := new(DryRun)
+		v, err := NewWithOptions("/some/path", WithVagrantHome("/tmp/vagrant home"), WithDryRun(plan))
+		require.NoError(t, err)
+
+		require.NoError(t, v.Halt("srv-2"))
+
+		expected := PlannedCommand{
+			Executable: "vagrant",
+			Args:       []string{"halt", "--machine-readable", "srv-2"},
+			Env:        []string{"VAGRANT_HOME=/tmp/vagrant home"},
+			Dir:        "/some/path",
+			Machines:   []string{"srv-2"},
+		}
+		require.Len(t, plan.Commands(), 1)
+		assert.Equal(t, expected, plan.Commands()[0])
+		assert.Equal(t, `'VAGRANT_HOME=/tmp/vagrant home' vagrant halt --machine-readable srv-2`, plan.Commands()[0].String())
+	})
+}
+
+func TestPlannedCommandString(t *testing.T) {
+	testcases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			"quotes",
+			[]string{"ssh", "--no-tty", "--command", `echo "hi" 'there'`, ""},
+			`vagrant ssh --no-tty --command 'echo "hi" '\''there'\''' ''`,
+		},
+		{
+			"expansions",
+			[]string{"ssh", "--command", "echo $HOME `whoami`"},
+			"vagrant ssh --command 'echo $HOME `whoami`'",
+		},
+		{
+			"plain",
+			[]string{"up", "--provider=virtualbox", "srv-1"},
+			"vagrant up --provider=virtualbox srv-1",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := PlannedCommand{Executable: "vagrant", Args: tc.args}
+			assert.Equal(t, tc.expected, c.String())
+		})
+	}
+}
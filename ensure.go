@@ -0,0 +1,135 @@
+package vagrantexec
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Operation is a command issued to converge a machine to a desired state.
+type Operation string
+
+const (
+	// OperationNone means that the machine was already in the desired state.
+	OperationNone Operation = "none"
+	// OperationUp means that the machine was brought up with Vagrant.Up.
+	OperationUp Operation = "up"
+	// OperationResume means that the machine was resumed with Vagrant.Resume.
+	OperationResume Operation = "resume"
+	// OperationHalt means that the machine was stopped with Vagrant.Halt.
+	OperationHalt Operation = "halt"
+	// OperationDestroy means that the machine was destroyed with Vagrant.Destroy.
+	OperationDestroy Operation = "destroy"
+)
+
+// EnsureAction describes the operation applied to a machine by EnsureRunning, EnsureStopped or EnsureAbsent.
+type EnsureAction struct {
+	Machine string
+	// State is the state of the machine before the operation.
+	State     MachineState
+	Operation Operation
+}
+
+// EnsureRunning brings the target machines up unless they are already running. Suspended and paused machines are
+// resumed, and machines in any other state from which Vagrant.Up can recover are brought up. Nothing is done when any
+// machine is in a state that cannot be converged, e.g. Stuck or Stopping.
+//
+// The actions that completed successfully are returned, including those that did not require any operation.
+func (w wrapper) EnsureRunning(targets ...string) ([]EnsureAction, error) {
+	return w.EnsureRunningContext(context.Background(), targets...)
+}
+
+// EnsureRunningContext is like EnsureRunning but includes a context.
+func (w wrapper) EnsureRunningContext(ctx context.Context, targets ...string) ([]EnsureAction, error) {
+	return w.ensure(ctx, "running", func(status MachineStatus) (Operation, bool) {
+		switch {
+		case status.State == Running:
+			return OperationNone, true
+		case status.State == Saved, status.State == Paused:
+			return OperationResume, true
+		case status.IsRunnable():
+			return OperationUp, true
+		}
+		return "", false
+	}, targets)
+}
+
+// EnsureStopped halts the target machines unless they are already powered off, aborted or not created. Nothing is
+// done when any machine is in a state that cannot be converged, e.g. Stuck or Saving.
+//
+// The actions that completed successfully are returned, including those that did not require any operation.
+func (w wrapper) EnsureStopped(targets ...string) ([]EnsureAction, error) {
+	return w.EnsureStoppedContext(context.Background(), targets...)
+}
+
+// EnsureStoppedContext is like EnsureStopped but includes a context.
+func (w wrapper) EnsureStoppedContext(ctx context.Context, targets ...string) ([]EnsureAction, error) {
+	return w.ensure(ctx, "stopped", func(status MachineStatus) (Operation, bool) {
+		switch status.State {
+		case PowerOff, Aborted, NotCreated:
+			return OperationNone, true
+		case Running, Saved, Paused, GuruMeditation:
+			return OperationHalt, true
+		}
+		return "", false
+	}, targets)
+}
+
+// EnsureAbsent destroys the target machines unless they are not created. Nothing is done when any machine is in a
+// state that cannot be converged, e.g. Inaccessible or Stopping.
+//
+// The actions that completed successfully are returned, including those that did not require any operation.
+func (w wrapper) EnsureAbsent(targets ...string) ([]EnsureAction, error) {
+	return w.EnsureAbsentContext(context.Background(), targets...)
+}
+
+// EnsureAbsentContext is like EnsureAbsent but includes a context.
+func (w wrapper) EnsureAbsentContext(ctx context.Context, targets ...string) ([]EnsureAction, error) {
+	return w.ensure(ctx, "absent", func(status MachineStatus) (Operation, bool) {
+		switch status.State {
+		case NotCreated:
+			return OperationNone, true
+		case Running, Saved, Paused, PowerOff, Aborted, GuruMeditation:
+			return OperationDestroy, true
+		}
+		return "", false
+	}, targets)
+}
+
+// ensure plans the operation required by every target machine and applies them one machine at a time. The plan
+// function returns false when a machine cannot be converged from its current state, in which case no operation is
+// applied to any machine.
+func (w wrapper) ensure(ctx context.Context, desired string, plan func(MachineStatus) (Operation, bool), targets []string) (actions []EnsureAction, err error) {
+	statuses, err := w.StatusContext(ctx, targets...)
+	if err != nil {
+		return
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+
+	planned := make([]EnsureAction, 0, len(statuses))
+	for _, status := range statuses {
+		op, ok := plan(status)
+		if !ok {
+			return nil, fmt.Errorf("cannot ensure machine %s is %s: it is %s", status.Name, desired, status.State)
+		}
+		planned = append(planned, EnsureAction{Machine: status.Name, State: status.State, Operation: op})
+	}
+
+	for _, action := range planned {
+		switch action.Operation {
+		case OperationUp:
+			err = w.UpContext(ctx, action.Machine)
+		case OperationResume:
+			err = w.ResumeContext(ctx, action.Machine)
+		case OperationHalt:
+			err = w.HaltContext(ctx, action.Machine)
+		case OperationDestroy:
+			err = w.DestroyContext(ctx, action.Machine)
+		}
+		if err != nil {
+			return
+		}
+		actions = append(actions, action)
+	}
+	return
+}
@@ -0,0 +1,120 @@
+package vagrantexec
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockEnsure creates a runner that reports the given machine states, in the form name=state, when the status of
+// every machine is queried.
+func mockEnsure(machines ...string) *mockRunner {
+	var out []byte
+	for _, m := range machines {
+		kv := strings.SplitN(m, "=", 2)
+		out = append(out, statusOutput(kv[0], kv[1])...)
+	}
+
+	runner := new(mockRunner)
+	runner.On("Execute", "vagrant", []string{"status", "--machine-readable"}).Return(out, nil)
+	return runner
+}
+
+func TestEnsureRunning(t *testing.T) {
+	t.Run("converge", func(t *testing.T) {
+		runner := mockEnsure("srv-4=not_created", "srv-1=running", "srv-2=saved", "srv-3=poweroff")
+		runner.On("Execute", "vagrant", []string{"resume", "--machine-readable", "srv-2"}).Return(nil, nil).Once()
+		runner.On("Execute", "vagrant", []string{"up", "--machine-readable", "srv-3"}).Return(nil, nil).Once()
+		runner.On("Execute", "vagrant", []string{"up", "--machine-readable", "srv-4"}).Return(nil, nil).Once()
+
+		actions, err := mockedWrapper(runner).EnsureRunning()
+		require.NoError(t, err)
+		expected := []EnsureAction{
+			{Machine: "srv-1", State: Running, Operation: OperationNone},
+			{Machine: "srv-2", State: Saved, Operation: OperationResume},
+			{Machine: "srv-3", State: PowerOff, Operation: OperationUp},
+			{Machine: "srv-4", State: NotCreated, Operation: OperationUp},
+		}
+		assert.Equal(t, expected, actions)
+		runner.AssertExpectations(t)
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		runner := mockEnsure("srv-1=poweroff", "srv-2=stuck")
+
+		actions, err := mockedWrapper(runner).EnsureRunning()
+		assert.EqualError(t, err, "cannot ensure machine srv-2 is running: it is Stuck")
+		assert.Empty(t, actions)
+		runner.AssertNumberOfCalls(t, "Execute", 1)
+	})
+
+	t.Run("partial_failure", func(t *testing.T) {
+		runner := mockEnsure("srv-1=poweroff", "srv-2=poweroff")
+		runner.On("Execute", "vagrant", []string{"up", "--machine-readable", "srv-1"}).Return(nil, nil).Once()
+		runner.On("Execute", "vagrant", []string{"up", "--machine-readable", "srv-2"}).Return(nil, errors.New("runner error")).Once()
+
+		actions, err := mockedWrapper(runner).EnsureRunning()
+		assert.EqualError(t, err, "runner error")
+		assert.Equal(t, []EnsureAction{{Machine: "srv-1", State: PowerOff, Operation: OperationUp}}, actions)
+	})
+
+	t.Run("status_error", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"status", "--machine-readable", "srv-1"})(nil, errors.New("runner error"))
+
+		_, err := w.EnsureRunning("srv-1")
+		assert.EqualError(t, err, "runner error")
+	})
+}
+
+func TestEnsureStopped(t *testing.T) {
+	t.Run("converge", func(t *testing.T) {
+		runner := mockEnsure("srv-1=running", "srv-2=saved", "srv-3=poweroff", "srv-4=not_created", "srv-5=aborted")
+		runner.On("Execute", "vagrant", []string{"halt", "--machine-readable", "srv-1"}).Return(nil, nil).Once()
+		runner.On("Execute", "vagrant", []string{"halt", "--machine-readable", "srv-2"}).Return(nil, nil).Once()
+
+		actions, err := mockedWrapper(runner).EnsureStopped()
+		require.NoError(t, err)
+		expected := []EnsureAction{
+			{Machine: "srv-1", State: Running, Operation: OperationHalt},
+			{Machine: "srv-2", State: Saved, Operation: OperationHalt},
+			{Machine: "srv-3", State: PowerOff, Operation: OperationNone},
+			{Machine: "srv-4", State: NotCreated, Operation: OperationNone},
+			{Machine: "srv-5", State: Aborted, Operation: OperationNone},
+		}
+		assert.Equal(t, expected, actions)
+		runner.AssertExpectations(t)
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		runner := mockEnsure("srv-1=saving")
+
+		_, err := mockedWrapper(runner).EnsureStopped()
+		assert.EqualError(t, err, "cannot ensure machine srv-1 is stopped: it is Saving")
+	})
+}
+
+func TestEnsureAbsent(t *testing.T) {
+	t.Run("converge", func(t *testing.T) {
+		runner := mockEnsure("srv-1=running", "srv-2=not_created")
+		runner.On("Execute", "vagrant", []string{"destroy", "--force", "--machine-readable", "srv-1"}).Return(nil, nil).Once()
+
+		actions, err := mockedWrapper(runner).EnsureAbsent()
+		require.NoError(t, err)
+		expected := []EnsureAction{
+			{Machine: "srv-1", State: Running, Operation: OperationDestroy},
+			{Machine: "srv-2", State: NotCreated, Operation: OperationNone},
+		}
+		assert.Equal(t, expected, actions)
+		runner.AssertExpectations(t)
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		runner := mockEnsure("srv-1=inaccessible")
+
+		_, err := mockedWrapper(runner).EnsureAbsent()
+		assert.EqualError(t, err, "cannot ensure machine srv-1 is absent: it is Inaccessible")
+	})
+}
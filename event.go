@@ -0,0 +1,185 @@
+package vagrantexec
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventHandler is invoked with every event emitted while a lifecycle command runs.
+type EventHandler func(event Event)
+
+// Event is a single record of machine-readable output emitted by Vagrant. The concrete type of an event is one of
+// UIEvent, ActionEvent, ErrorExitEvent, StateEvent, MetadataEvent, ProvisionerEvent or RawEvent.
+type Event interface {
+	Header() EventHeader
+}
+
+// EventHeader contains the fields common to every event.
+type EventHeader struct {
+	// Timestamp is the time at which Vagrant emitted the event.
+	Timestamp time.Time
+	// Target is the name of the machine the event applies to. It is empty for events that apply to the environment.
+	Target string
+}
+
+// Header returns the common event fields.
+func (h EventHeader) Header() EventHeader {
+	return h
+}
+
+// UIEvent is human-readable output that Vagrant would normally print to the terminal.
+type UIEvent struct {
+	EventHeader
+	// Level is the type of UI message, e.g. "info", "output", "detail", "warn", "error" or "success".
+	Level   string
+	Message string
+}
+
+// ActionEvent marks the start or end of an action performed on a machine, e.g. "up" or "halt".
+type ActionEvent struct {
+	EventHeader
+	Action string
+	// Phase is either "start" or "end".
+	Phase string
+}
+
+// ErrorExitEvent is emitted when Vagrant exits because of an error.
+type ErrorExitEvent struct {
+	EventHeader
+	// ErrorClass is the Ruby class of the error, e.g. "Vagrant::Errors::VagrantfileSyntaxError".
+	ErrorClass string
+	Message    string
+}
+
+// StateEvent reports the state of a machine.
+type StateEvent struct {
+	EventHeader
+	State MachineState
+}
+
+// MetadataEvent reports a piece of metadata about a machine, e.g. its provider.
+type MetadataEvent struct {
+	EventHeader
+	Key   string
+	Value string
+}
+
+// RawEvent contains any other type of machine-readable output.
+type RawEvent struct {
+	EventHeader
+	Type string
+	Data []string
+}
+
+// ProvisionerEvent marks the start or end of a single provisioner run on a machine. Vagrant does not report these
+// directly, so they are derived from the UI and action events of commands that provision machines.
+type ProvisionerEvent struct {
+	EventHeader
+	// Provisioner is the name of the provisioner, or its type when it has no name.
+	Provisioner string
+	// Type is the type of provisioner, e.g. "shell" or "ansible".
+	Type string
+	// Phase is either "start" or "end".
+	Phase string
+}
+
+// provisionerStartExtractor matches the UI message Vagrant prints before running a provisioner. Named provisioners are
+// followed by their type in parentheses.
+var provisionerStartExtractor = regexp.MustCompile(`^Running provisioner: (.+?)(?: \((.+)\))?\.\.\.$`)
+
+// provisionerTracker derives ProvisionerEvents from the events of a single command.
+type provisionerTracker struct {
+	running map[string]ProvisionerEvent // keyed by target
+}
+
+// track returns the provisioner events implied by an event. Provisioners that ended should be emitted before the event
+// and the ones that started after it. A provisioner ends when the next one starts on the same machine or when the
+// machine's action ends.
+func (t *provisionerTracker) track(event Event) (ended, started []Event) {
+	switch e := event.(type) {
+	case UIEvent:
+		ms := provisionerStartExtractor.FindStringSubmatch(e.Message)
+		if ms == nil {
+			return
+		}
+		ended = t.end(e.EventHeader)
+
+		start := ProvisionerEvent{EventHeader: e.EventHeader, Provisioner: ms[1], Type: ms[2], Phase: "start"}
+		if len(start.Type) == 0 {
+			start.Type = start.Provisioner
+		}
+		if t.running == nil {
+			t.running = map[string]ProvisionerEvent{}
+		}
+		t.running[e.Target] = start
+		started = []Event{start}
+	case ActionEvent:
+		if e.Phase == "end" {
+			ended = t.end(e.EventHeader)
+		}
+	}
+	return
+}
+
+// finish returns end events for every provisioner that is still running once the command has exited.
+func (t *provisionerTracker) finish(at time.Time) (derived []Event) {
+	targets := make([]string, 0, len(t.running))
+	for target := range t.running {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	for _, target := range targets {
+		derived = append(derived, t.end(EventHeader{Timestamp: at, Target: target})...)
+	}
+	return
+}
+
+// end returns an end event for the provisioner running on the header's target, if any.
+func (t *provisionerTracker) end(header EventHeader) []Event {
+	started, ok := t.running[header.Target]
+	if !ok {
+		return nil
+	}
+	delete(t.running, header.Target)
+
+	ended := started
+	ended.EventHeader = header
+	ended.Phase = "end"
+	return []Event{ended}
+}
+
+// newEvent converts a single entry of machine-readable output into its typed event.
+func newEvent(entry machineOutputEntry) Event {
+	header := EventHeader{Target: entry.target}
+	if secs, err := strconv.ParseInt(entry.timestamp, 10, 64); err == nil {
+		header.Timestamp = time.Unix(secs, 0)
+	}
+
+	data := make([]string, len(entry.data))
+	for i, d := range entry.data {
+		data[i] = unescapeMachineData(d)
+	}
+
+	switch {
+	case entry.mType == "ui" && len(data) >= 2:
+		return UIEvent{EventHeader: header, Level: data[0], Message: strings.Join(data[1:], ",")}
+	case entry.mType == "action" && len(data) >= 2:
+		return ActionEvent{EventHeader: header, Action: data[0], Phase: data[1]}
+	case entry.mType == "error-exit" && len(data) >= 2:
+		return ErrorExitEvent{EventHeader: header, ErrorClass: data[0], Message: strings.Join(data[1:], ",")}
+	case entry.mType == "state" && len(data) >= 1:
+		return StateEvent{EventHeader: header, State: ToMachineState(data[0])}
+	case entry.mType == "metadata" && len(data) >= 2:
+		return MetadataEvent{EventHeader: header, Key: data[0], Value: strings.Join(data[1:], ",")}
+	}
+	return RawEvent{EventHeader: header, Type: entry.mType, Data: data}
+}
+
+// unescapeMachineData restores the characters Vagrant escapes in machine-readable data fields.
+func unescapeMachineData(data string) string {
+	return strings.NewReplacer(`%!(VAGRANT_COMMA)`, ",", `\n`, "\n", `\r`, "\r").Replace(data)
+}
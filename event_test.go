@@ -0,0 +1,60 @@
+package vagrantexec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEvent(t *testing.T) {
+	header := EventHeader{Timestamp: time.Unix(1562175814, 0), Target: "srv-1"}
+
+	testcases := []struct {
+		name     string
+		entry    machineOutputEntry
+		expected Event
+	}{
+		{
+			"ui",
+			machineOutputEntry{"1562175814", "srv-1", "ui", []string{"info", `Line 1\nLine 2%!(VAGRANT_COMMA) cont`}},
+			UIEvent{EventHeader: header, Level: "info", Message: "Line 1\nLine 2, cont"},
+		},
+		{
+			"action",
+			machineOutputEntry{"1562175814", "srv-1", "action", []string{"halt", "end"}},
+			ActionEvent{EventHeader: header, Action: "halt", Phase: "end"},
+		},
+		{
+			"error_exit",
+			machineOutputEntry{"1562175814", "srv-1", "error-exit", []string{"Vagrant::Errors::VMNotFoundError", "not found"}},
+			ErrorExitEvent{EventHeader: header, ErrorClass: "Vagrant::Errors::VMNotFoundError", Message: "not found"},
+		},
+		{
+			"state",
+			machineOutputEntry{"1562175814", "srv-1", "state", []string{"poweroff"}},
+			StateEvent{EventHeader: header, State: PowerOff},
+		},
+		{
+			"metadata",
+			machineOutputEntry{"1562175814", "srv-1", "metadata", []string{"provider", "virtualbox"}},
+			MetadataEvent{EventHeader: header, Key: "provider", Value: "virtualbox"},
+		},
+		{
+			"raw",
+			machineOutputEntry{"1562175814", "srv-1", "provider-name", []string{"virtualbox"}},
+			RawEvent{EventHeader: header, Type: "provider-name", Data: []string{"virtualbox"}},
+		},
+		{
+			"bad_timestamp",
+			machineOutputEntry{"garbage", "", "action", []string{"up", "start"}},
+			ActionEvent{Action: "up", Phase: "start"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, newEvent(tc.entry))
+		})
+	}
+}
@@ -1,8 +1,17 @@
 module github.com/dominodatalab/vagrant-exec
 
-go 1.12
+go 1.18
 
 require (
 	github.com/sirupsen/logrus v1.4.2
 	github.com/stretchr/testify v1.3.0
+	golang.org/x/crypto v0.17.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/konsorten/go-windows-terminal-sequences v1.0.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/stretchr/objx v0.1.1 // indirect
+	golang.org/x/sys v0.15.0 // indirect
 )
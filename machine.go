@@ -64,6 +64,12 @@ var (
 		Aborted,
 		GuruMeditation,
 	}
+
+	// terminalStates contains a list of states that machines cannot leave without manual intervention.
+	terminalStates = []MachineState{
+		Stuck,
+		Inaccessible,
+	}
 )
 
 // MachineState denotes the state of a machine Vagrant is managing.
@@ -103,6 +109,23 @@ func (m MachineStatus) IsRunnable() (runnable bool) {
 	return
 }
 
+// GlobalMachineStatus encompasses the metadata Vagrant caches about a machine in any environment on the host.
+type GlobalMachineStatus struct {
+	MachineStatus
+	// ID uniquely identifies the machine across all environments. It can be used as a target from any directory.
+	ID string
+	// Directory is the directory of the Vagrantfile that defines the machine.
+	Directory string
+}
+
+// Vagrant creates a Vagrant CLI wrapper targeting the environment the machine belongs to.
+func (m GlobalMachineStatus) Vagrant(opts ...Option) (Vagrant, error) {
+	if len(m.Directory) == 0 {
+		return nil, fmt.Errorf("machine %s has no directory", m.ID)
+	}
+	return NewWithOptions(m.Directory, opts...)
+}
+
 // machineOutputEntry defines all of the components in a single line of machine-readable output.
 //
 // See https://www.vagrantup.com/docs/cli/machine-readable.html#format for more details.
@@ -117,24 +140,31 @@ type machineOutputEntry struct {
 func parseMachineReadable(machineOut []byte) (entries []machineOutputEntry, err error) {
 	scanner := bufio.NewScanner(strings.NewReader(string(machineOut)))
 	for scanner.Scan() {
-		line := scanner.Text()
-		row := strings.Split(line, ",")
-		if len(row) < 4 {
-			err = fmt.Errorf("invalid machine-readable format: %s", row)
+		var entry machineOutputEntry
+		if entry, err = parseMachineReadableLine(scanner.Text()); err != nil {
 			return
 		}
-
-		entries = append(entries, machineOutputEntry{
-			timestamp: row[0],
-			target:    row[1],
-			mType:     row[2],
-			data:      row[3:],
-		})
+		entries = append(entries, entry)
 	}
 	err = scanner.Err()
 	return
 }
 
+// parseMachineReadableLine converts a single line of machine-readable output into a machineOutputEntry.
+func parseMachineReadableLine(line string) (machineOutputEntry, error) {
+	row := strings.Split(line, ",")
+	if len(row) < 4 {
+		return machineOutputEntry{}, fmt.Errorf("invalid machine-readable format: %s", row)
+	}
+
+	return machineOutputEntry{
+		timestamp: row[0],
+		target:    row[1],
+		mType:     row[2],
+		data:      row[3:],
+	}, nil
+}
+
 // pluckEntryData extracts a single data field from a collection of entries.
 func pluckEntryData(entries []machineOutputEntry, messageType string) ([]string, error) {
 	for _, e := range entries {
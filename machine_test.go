@@ -3,7 +3,9 @@ package vagrantexec
 import (
 	"testing"
 
+	"github.com/dominodatalab/vagrant-exec/command"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMachineStateString(t *testing.T) {
@@ -104,3 +106,21 @@ func TestMachineStatusIsRunnable(t *testing.T) {
 		assert.Equal(t, tc.expected, ms.IsRunnable())
 	}
 }
+
+func TestGlobalMachineStatusVagrant(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		m := GlobalMachineStatus{ID: "a1b2c3d", Directory: "/home/ci/builds/1234"}
+
+		v, err := m.Vagrant(WithExecutable("/opt/vagrant/bin/vagrant"))
+		require.NoError(t, err)
+
+		w := v.(wrapper)
+		assert.Equal(t, "/opt/vagrant/bin/vagrant", w.executable)
+		assert.Equal(t, "/home/ci/builds/1234", w.runner.(command.ShellRunner).Dir)
+	})
+
+	t.Run("no_directory", func(t *testing.T) {
+		_, err := GlobalMachineStatus{ID: "a1b2c3d"}.Vagrant()
+		assert.EqualError(t, err, "machine a1b2c3d has no directory")
+	})
+}
@@ -0,0 +1,363 @@
+package vagrantexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dominodatalab/vagrant-exec/command"
+	"golang.org/x/crypto/ssh"
+)
+
+// nativeSSHDialTimeout limits how long establishing a connection to a machine may take.
+const nativeSSHDialTimeout = 30 * time.Second
+
+// SSHExecutor runs commands on Vagrant machines via SSH. It is implemented by Vagrant and NativeSSH.
+type SSHExecutor interface {
+	SSH(nameOrID, command string) (cmdOutput string, err error)
+	SSHContext(ctx context.Context, nameOrID, command string) (cmdOutput string, err error)
+	SSHCommand(nameOrID, command string) (result SSHResult, err error)
+	SSHCommandContext(ctx context.Context, nameOrID, command string) (result SSHResult, err error)
+	SSHWithInput(nameOrID, command string, input io.Reader) (result SSHResult, err error)
+	SSHWithInputContext(ctx context.Context, nameOrID, command string, input io.Reader) (result SSHResult, err error)
+}
+
+var (
+	_ SSHExecutor = Vagrant(nil)
+	_ SSHExecutor = (*NativeSSH)(nil)
+)
+
+// NativeSSH runs commands on Vagrant machines over SSH connections made directly from Go instead of spawning a vagrant
+// process for every command. Connection details are resolved once per machine with Vagrant.SSHConfig and connections
+// are reused until Close is called. It is safe for concurrent use.
+//
+// Machines that can only be reached through a ProxyCommand are not supported.
+type NativeSSH struct {
+	vagrant Vagrant
+
+	mu      sync.Mutex
+	configs map[string]SSHConfig
+	clients map[string]*ssh.Client
+	dialing map[string]*pendingDial
+}
+
+// pendingDial is a connection that is being established. Concurrent commands to the same machine wait for it instead
+// of dialing again.
+type pendingDial struct {
+	done   chan struct{}
+	client *ssh.Client
+	err    error
+}
+
+// NewNativeSSH creates a NativeSSH executor that resolves connection details using the given Vagrant wrapper.
+func NewNativeSSH(v Vagrant) *NativeSSH {
+	return &NativeSSH{
+		vagrant: v,
+		configs: map[string]SSHConfig{},
+		clients: map[string]*ssh.Client{},
+		dialing: map[string]*pendingDial{},
+	}
+}
+
+// SSH executes a command on a Vagrant machine and returns its standard output. It behaves like Vagrant.SSH: a
+// command.ExitError containing standard error is returned when the command exits with a non-zero status. You can use
+// an empty string as the nameOrID if you only have one VM defined in your Vagrantfile.
+func (n *NativeSSH) SSH(nameOrID, cmd string) (string, error) {
+	return n.SSHContext(context.Background(), nameOrID, cmd)
+}
+
+// SSHContext is like SSH but includes a context. The command is killed and a command.CancelError is returned when the
+// context is done before it completes.
+func (n *NativeSSH) SSHContext(ctx context.Context, nameOrID, cmd string) (string, error) {
+	result, err := n.SSHCommandContext(ctx, nameOrID, cmd)
+	if err == nil && result.ExitCode != 0 {
+		err = command.NewExitError("ssh", result.ExitCode, result.Stderr)
+	}
+	return result.Stdout, err
+}
+
+// SSHCommand executes a command on a Vagrant machine and returns its output and exit code separately. Like
+// Vagrant.SSHCommand, a command that fails on the guest is not an error. An error is only returned when the command
+// could not be run, e.g. because the machine cannot be reached.
+func (n *NativeSSH) SSHCommand(nameOrID, cmd string) (SSHResult, error) {
+	return n.SSHCommandContext(context.Background(), nameOrID, cmd)
+}
+
+// SSHCommandContext is like SSHCommand but includes a context. The command is killed and a command.CancelError is
+// returned when the context is done before it completes.
+func (n *NativeSSH) SSHCommandContext(ctx context.Context, nameOrID, cmd string) (SSHResult, error) {
+	return n.SSHWithInputContext(ctx, nameOrID, cmd, nil)
+}
+
+// SSHWithInput is like SSHCommand but streams the input to the standard input of the command.
+func (n *NativeSSH) SSHWithInput(nameOrID, cmd string, input io.Reader) (SSHResult, error) {
+	return n.SSHWithInputContext(context.Background(), nameOrID, cmd, input)
+}
+
+// SSHWithInputContext is like SSHWithInput but includes a context.
+func (n *NativeSSH) SSHWithInputContext(ctx context.Context, nameOrID, cmd string, input io.Reader) (SSHResult, error) {
+	start := time.Now()
+	session, err := n.session(ctx, nameOrID)
+	if err != nil {
+		return SSHResult{Duration: time.Since(start)}, err
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdin = input
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	done := make(chan error, 1)
+	go func() { done <- session.Run(cmd) }()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		_ = session.Signal(ssh.SIGKILL)
+		session.Close()
+		<-done
+		err = command.NewCancelError("ssh", ctx.Err())
+	}
+
+	result := SSHResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
+	}
+	if ee, ok := err.(*ssh.ExitError); ok {
+		result.ExitCode = ee.ExitStatus()
+		err = nil
+	}
+	return result, err
+}
+
+// Close closes every pooled connection.
+func (n *NativeSSH) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var err error
+	for target, client := range n.clients {
+		if cerr := client.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(n.clients, target)
+	}
+	return err
+}
+
+// session opens a new session on the pooled connection to a machine. Stale connections, e.g. to a machine that was
+// restarted, are replaced once.
+func (n *NativeSSH) session(ctx context.Context, nameOrID string) (*ssh.Session, error) {
+	for attempt := 0; ; attempt++ {
+		client, err := n.client(ctx, nameOrID)
+		if err != nil {
+			return nil, err
+		}
+
+		session, err := openSession(ctx, client)
+		if err == nil {
+			return session, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		n.discard(nameOrID, client)
+
+		if attempt > 0 {
+			return nil, err
+		}
+	}
+}
+
+// client returns the pooled connection to a machine, establishing it when necessary. The lock is only held while the
+// pool is accessed, so a machine that is slow to connect does not hold up commands to other machines.
+func (n *NativeSSH) client(ctx context.Context, nameOrID string) (*ssh.Client, error) {
+	for {
+		n.mu.Lock()
+		if client, ok := n.clients[nameOrID]; ok {
+			n.mu.Unlock()
+			return client, nil
+		}
+
+		if pending, ok := n.dialing[nameOrID]; ok {
+			n.mu.Unlock()
+
+			select {
+			case <-pending.done:
+			case <-ctx.Done():
+				return nil, command.NewCancelError("ssh", ctx.Err())
+			}
+			if _, cancelled := pending.err.(command.CancelError); pending.err != nil && !cancelled {
+				return nil, pending.err
+			}
+			continue // the connection is pooled now, or has to be dialed again because the other command gave up
+		}
+
+		pending := &pendingDial{done: make(chan struct{})}
+		n.dialing[nameOrID] = pending
+		config, cached := n.configs[nameOrID]
+		n.mu.Unlock()
+
+		config, client, err := n.dial(ctx, nameOrID, config, cached)
+
+		n.mu.Lock()
+		delete(n.dialing, nameOrID)
+		if err == nil {
+			n.configs[nameOrID] = config
+			n.clients[nameOrID] = client
+		} else {
+			delete(n.configs, nameOrID) // connection details may have changed
+		}
+		n.mu.Unlock()
+
+		pending.client, pending.err = client, err
+		close(pending.done)
+		return client, err
+	}
+}
+
+// dial resolves the connection details of a machine unless they are cached and connects to it.
+func (n *NativeSSH) dial(ctx context.Context, nameOrID string, config SSHConfig, cached bool) (SSHConfig, *ssh.Client, error) {
+	if !cached {
+		configs, err := n.vagrant.SSHConfigContext(ctx, appendNameOrID(nil, nameOrID)...)
+		if err != nil {
+			return config, nil, err
+		}
+		if len(configs) != 1 {
+			return config, nil, fmt.Errorf("expected ssh-config for a single machine, got %d", len(configs))
+		}
+		config = configs[0]
+	}
+
+	client, err := dialSSH(ctx, config)
+	if err != nil && ctx.Err() != nil {
+		err = command.NewCancelError("ssh", ctx.Err())
+	}
+	return config, client, err
+}
+
+// discard closes and removes a connection from the pool unless it has already been replaced.
+func (n *NativeSSH) discard(nameOrID string, client *ssh.Client) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.clients[nameOrID] == client {
+		delete(n.clients, nameOrID)
+	}
+	client.Close()
+}
+
+// openSession opens a session on a connection. A connection that went away without being closed, e.g. because the
+// machine was suspended, never answers, so the wait is bounded by the context and nativeSSHDialTimeout.
+func openSession(ctx context.Context, client *ssh.Client) (*ssh.Session, error) {
+	type result struct {
+		session *ssh.Session
+		err     error
+	}
+	opened := make(chan result, 1)
+	go func() {
+		session, err := client.NewSession()
+		opened <- result{session, err}
+	}()
+
+	timer := time.NewTimer(nativeSSHDialTimeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-opened:
+		return r.session, r.err
+	case <-timer.C:
+		client.Close() // unblocks NewSession
+		return nil, errors.New("timed out opening ssh session")
+	case <-ctx.Done():
+		go func() { // the connection may be fine and shared with other commands, so only give up on the session
+			if r := <-opened; r.err == nil {
+				r.session.Close()
+			}
+		}()
+		return nil, command.NewCancelError("ssh", ctx.Err())
+	}
+}
+
+// dialSSH establishes an SSH connection using the settings reported by vagrant.
+func dialSSH(ctx context.Context, config SSHConfig) (*ssh.Client, error) {
+	if len(config.ProxyCommand) > 0 {
+		return nil, fmt.Errorf("cannot connect to %s: proxy commands are not supported", config.Host)
+	}
+
+	var signers []ssh.Signer
+	for _, file := range config.IdentityFile {
+		key, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue // vagrant lists every candidate key, not all of them have to exist
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse identity file %s: %v", file, err)
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("cannot connect to %s: no usable identity file", config.Host)
+	}
+
+	port := config.Port
+	if port == 0 {
+		port = 22
+	}
+	addr := net.JoinHostPort(config.HostName, strconv.Itoa(port))
+
+	clientConfig := &ssh.ClientConfig{
+		User: config.User,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		// vagrant disables host key checking because host keys change every time a machine is recreated
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         nativeSSHDialTimeout,
+	}
+
+	dialer := net.Dialer{Timeout: nativeSSHDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(nativeSSHDialTimeout) // bound the handshake as well
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	handshakeDone, watchDone := make(chan struct{}), make(chan struct{})
+	go func() { // abort the handshake when the context is cancelled
+		defer close(watchDone)
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-handshakeDone:
+		}
+	}()
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, clientConfig)
+	close(handshakeDone)
+	<-watchDone
+	if err == nil && ctx.Err() != nil {
+		c.Close()
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
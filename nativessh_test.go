@@ -0,0 +1,334 @@
+package vagrantexec
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dominodatalab/vagrant-exec/command"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// testSSHServer is a minimal SSH server that answers exec requests. The "fail" command exits with status 3, "cat"
+// echoes its input, "sleep" blocks until the session is closed and any other command echoes itself.
+type testSSHServer struct {
+	addr        *net.TCPAddr
+	keyFile     string
+	connections int32
+	listener    net.Listener
+	// stallSessions makes the server ignore new sessions, like a connection that went away without being closed.
+	stallSessions int32
+}
+
+func newTestSSHServer(t *testing.T) *testSSHServer {
+	dir, err := ioutil.TempDir("", "vagrant-exec")
+	require.NoError(t, err)
+
+	clientKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyFile := filepath.Join(dir, "private_key")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(clientKey)})
+	require.NoError(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+
+	authorizedKey, err := ssh.NewPublicKey(&clientKey.PublicKey)
+	require.NoError(t, err)
+	hostKey, err := ssh.NewSignerFromKey(clientKey)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "vagrant" && string(key.Marshal()) == string(authorizedKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unauthorized")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &testSSHServer{
+		addr:     listener.Addr().(*net.TCPAddr),
+		keyFile:  keyFile,
+		listener: listener,
+	}
+	go server.serve(config)
+
+	t.Cleanup(func() {
+		listener.Close()
+		os.RemoveAll(dir)
+	})
+	return server
+}
+
+func (s *testSSHServer) serve(config *ssh.ServerConfig) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		atomic.AddInt32(&s.connections, 1)
+
+		go func() {
+			_, chans, reqs, err := ssh.NewServerConn(conn, config)
+			if err != nil {
+				return
+			}
+			go ssh.DiscardRequests(reqs)
+
+			for newChannel := range chans {
+				if atomic.LoadInt32(&s.stallSessions) > 0 {
+					continue
+				}
+				channel, requests, err := newChannel.Accept()
+				if err != nil {
+					continue
+				}
+				go handleTestSession(channel, requests)
+			}
+		}()
+	}
+}
+
+func handleTestSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	for req := range requests {
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+
+		var payload struct{ Command string }
+		ssh.Unmarshal(req.Payload, &payload)
+
+		status := 0
+		switch payload.Command {
+		case "fail":
+			fmt.Fprint(channel.Stderr(), "something broke\n")
+			status = 3
+		case "cat":
+			io.Copy(channel, channel)
+		case "sleep":
+			for range requests { // wait for the client to give up
+			}
+			return
+		default:
+			fmt.Fprintf(channel, "ran: %s\n", payload.Command)
+		}
+
+		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+		return
+	}
+}
+
+func (s *testSSHServer) sshConfig(host string) []byte {
+	return []byte(fmt.Sprintf("Host %s\n  HostName 127.0.0.1\n  User vagrant\n  Port %d\n  IdentityFile /does/not/exist\n  IdentityFile %s\n",
+		host, s.addr.Port, s.keyFile))
+}
+
+func TestNativeSSH(t *testing.T) {
+	server := newTestSSHServer(t)
+
+	t.Run("pooled", func(t *testing.T) {
+		runner := new(mockRunner)
+		runner.On("Execute", "vagrant", []string{"ssh-config", "srv-1"}).Return(server.sshConfig("srv-1"), nil).Once()
+
+		n := NewNativeSSH(mockedWrapper(runner))
+		defer n.Close()
+
+		before := atomic.LoadInt32(&server.connections)
+		for i := 0; i < 3; i++ {
+			out, err := n.SSH("srv-1", "hostname "+strconv.Itoa(i))
+			require.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf("ran: hostname %d\n", i), out)
+		}
+		assert.Equal(t, before+1, atomic.LoadInt32(&server.connections))
+		runner.AssertExpectations(t)
+	})
+
+	t.Run("single_machine", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"ssh-config"})(server.sshConfig("default"), nil)
+
+		n := NewNativeSSH(w)
+		defer n.Close()
+
+		out, err := n.SSH("", "uptime")
+		require.NoError(t, err)
+		assert.Equal(t, "ran: uptime\n", out)
+	})
+
+	t.Run("exit_error", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"ssh-config", "srv-1"})(server.sshConfig("srv-1"), nil)
+
+		n := NewNativeSSH(w)
+		defer n.Close()
+
+		_, err := n.SSH("srv-1", "fail")
+		require.IsType(t, command.ExitError{}, err)
+		assert.Equal(t, 3, err.(command.ExitError).ExitStatus())
+		assert.Equal(t, "ssh exited with status 3: something broke", err.Error())
+	})
+
+	t.Run("command_result", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"ssh-config", "srv-1"})(server.sshConfig("srv-1"), nil)
+
+		n := NewNativeSSH(w)
+		defer n.Close()
+
+		result, err := n.SSHCommand("srv-1", "fail")
+		require.NoError(t, err)
+		assert.Empty(t, result.Stdout)
+		assert.Equal(t, "something broke\n", result.Stderr)
+		assert.Equal(t, 3, result.ExitCode)
+		assert.True(t, result.Duration > 0)
+	})
+
+	t.Run("with_input", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"ssh-config", "srv-1"})(server.sshConfig("srv-1"), nil)
+
+		n := NewNativeSSH(w)
+		defer n.Close()
+
+		result, err := n.SSHWithInput("srv-1", "cat", strings.NewReader("streamed input"))
+		require.NoError(t, err)
+		assert.Equal(t, "streamed input", result.Stdout)
+		assert.Equal(t, 0, result.ExitCode)
+	})
+
+	t.Run("reconnect", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"ssh-config", "srv-1"})(server.sshConfig("srv-1"), nil)
+
+		n := NewNativeSSH(w)
+		defer n.Close()
+
+		_, err := n.SSH("srv-1", "first")
+		require.NoError(t, err)
+		n.clients["srv-1"].Close() // simulate a dropped connection
+
+		out, err := n.SSH("srv-1", "second")
+		require.NoError(t, err)
+		assert.Equal(t, "ran: second\n", out)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"ssh-config", "srv-1"})(server.sshConfig("srv-1"), nil)
+
+		n := NewNativeSSH(w)
+		defer n.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := n.SSHContext(ctx, "srv-1", "sleep")
+		require.IsType(t, command.CancelError{}, err)
+		assert.Equal(t, context.DeadlineExceeded, err.(command.CancelError).Cause())
+	})
+
+	t.Run("slow_machine", func(t *testing.T) {
+		// the listener accepts connections but never answers the handshake
+		silent, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer silent.Close()
+
+		accepted := make(chan net.Conn, 1)
+		go func() {
+			if conn, err := silent.Accept(); err == nil {
+				accepted <- conn
+			}
+		}()
+
+		slowConfig := fmt.Sprintf("Host srv-2\n  HostName 127.0.0.1\n  Port %d\n  IdentityFile %s\n",
+			silent.Addr().(*net.TCPAddr).Port, server.keyFile)
+
+		runner := new(mockRunner)
+		runner.On("Execute", "vagrant", []string{"ssh-config", "srv-1"}).Return(server.sshConfig("srv-1"), nil)
+		runner.On("Execute", "vagrant", []string{"ssh-config", "srv-2"}).Return([]byte(slowConfig), nil)
+
+		n := NewNativeSSH(mockedWrapper(runner))
+		defer n.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		slowErr := make(chan error, 1)
+		go func() {
+			_, err := n.SSHContext(ctx, "srv-2", "uptime")
+			slowErr <- err
+		}()
+		conn := <-accepted // srv-2 is dialing now
+		defer conn.Close()
+
+		start := time.Now()
+		out, err := n.SSH("srv-1", "uptime")
+		require.NoError(t, err)
+		assert.Equal(t, "ran: uptime\n", out)
+		assert.True(t, time.Since(start) < 2*time.Second, "blocked by a machine that is dialing")
+
+		cancel()
+		assert.IsType(t, command.CancelError{}, <-slowErr)
+	})
+
+	t.Run("stalled_session", func(t *testing.T) {
+		stalled := newTestSSHServer(t)
+		atomic.StoreInt32(&stalled.stallSessions, 1)
+
+		w := mockedWrapperFn([]string{"ssh-config", "srv-1"})(stalled.sshConfig("srv-1"), nil)
+
+		n := NewNativeSSH(w)
+		defer n.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		_, err := n.SSHContext(ctx, "srv-1", "uptime")
+		require.IsType(t, command.CancelError{}, err)
+		assert.Equal(t, context.DeadlineExceeded, err.(command.CancelError).Cause())
+	})
+
+	t.Run("multiple_machines", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"ssh-config"})(ioutil.ReadFile("testdata/ssh-config"))
+
+		_, err := NewNativeSSH(w).SSH("", "uptime")
+		assert.EqualError(t, err, "expected ssh-config for a single machine, got 2")
+	})
+
+	t.Run("proxy_command", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"ssh-config", "srv-2"})([]byte("Host srv-2\n  ProxyCommand nc %h %p\n"), nil)
+
+		_, err := NewNativeSSH(w).SSH("srv-2", "uptime")
+		assert.EqualError(t, err, "cannot connect to srv-2: proxy commands are not supported")
+	})
+
+	t.Run("no_identity", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"ssh-config", "srv-1"})([]byte("Host srv-1\n  IdentityFile /does/not/exist\n"), nil)
+
+		_, err := NewNativeSSH(w).SSH("srv-1", "uptime")
+		assert.EqualError(t, err, "cannot connect to srv-1: no usable identity file")
+	})
+
+	t.Run("ssh_config_error", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"ssh-config", "srv-1"})(nil, errors.New("runner error"))
+
+		_, err := NewNativeSSH(w).SSH("srv-1", "uptime")
+		assert.EqualError(t, err, "runner error")
+	})
+}
@@ -0,0 +1,204 @@
+package vagrantexec
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dominodatalab/vagrant-exec/command"
+	log "github.com/sirupsen/logrus"
+)
+
+// Option configures the Vagrant CLI wrapper created by NewWithOptions.
+type Option func(*options) error
+
+// options collects the settings applied by each Option.
+type options struct {
+	executable   string
+	logger       log.FieldLogger
+	runner       command.Runner
+	env          []string
+	timeout      time.Duration
+	pollInterval time.Duration
+	dryRun       *DryRun
+}
+
+// WithExecutable sets the path of the vagrant executable. The executable is looked up in PATH by default.
+func WithExecutable(path string) Option {
+	return func(o *options) error {
+		if len(path) == 0 {
+			return errors.New("executable cannot be empty")
+		}
+		o.executable = path
+		return nil
+	}
+}
+
+// WithLogger sets the logger used to report progress and command output. A new logrus logger at an info level is used
+// by default.
+func WithLogger(logger log.FieldLogger) Option {
+	return func(o *options) error {
+		if logger == nil {
+			return errors.New("logger cannot be nil")
+		}
+		o.logger = logger
+		return nil
+	}
+}
+
+// WithRunner sets the runner used to execute vagrant commands. A command.ShellRunner running in the Vagrantfile
+// directory is used by default.
+func WithRunner(runner command.Runner) Option {
+	return func(o *options) error {
+		if runner == nil {
+			return errors.New("runner cannot be nil")
+		}
+		o.runner = runner
+		return nil
+	}
+}
+
+// WithEnv adds environment variables in the form "KEY=value" to every vagrant command. It can only be combined with a
+// command.ShellRunner.
+func WithEnv(env ...string) Option {
+	return func(o *options) error {
+		for _, kv := range env {
+			if i := strings.Index(kv, "="); i < 1 {
+				return fmt.Errorf("invalid environment variable: %q", kv)
+			}
+		}
+		o.env = append(o.env, env...)
+		return nil
+	}
+}
+
+// WithVagrantHome sets VAGRANT_HOME, the directory where Vagrant stores boxes, plugins and other global state.
+func WithVagrantHome(dir string) Option {
+	return withEnvVar("VAGRANT_HOME", dir)
+}
+
+// WithVagrantCWD sets VAGRANT_CWD, the directory where Vagrant starts looking for a Vagrantfile. It takes precedence
+// over the directory given to NewWithOptions.
+func WithVagrantCWD(dir string) Option {
+	return withEnvVar("VAGRANT_CWD", dir)
+}
+
+// WithVagrantfileName sets VAGRANT_VAGRANTFILE, the name of the Vagrantfile to look for.
+func WithVagrantfileName(name string) Option {
+	return withEnvVar("VAGRANT_VAGRANTFILE", name)
+}
+
+// WithDefaultProvider sets VAGRANT_DEFAULT_PROVIDER, the provider used when a command does not specify one.
+func WithDefaultProvider(provider string) Option {
+	return withEnvVar("VAGRANT_DEFAULT_PROVIDER", provider)
+}
+
+// WithDotfilePath sets VAGRANT_DOTFILE_PATH, the directory where Vagrant stores the state of the environment's
+// machines instead of ".vagrant".
+func WithDotfilePath(path string) Option {
+	return withEnvVar("VAGRANT_DOTFILE_PATH", path)
+}
+
+// WithVagrantLog sets VAGRANT_LOG, the verbosity of Vagrant's own logging: "debug", "info", "warn" or "error".
+// Vagrant writes its logs to standard error.
+func WithVagrantLog(level string) Option {
+	switch level {
+	case "debug", "info", "warn", "error":
+		return withEnvVar("VAGRANT_LOG", level)
+	}
+	return func(*options) error {
+		return fmt.Errorf("invalid vagrant log level: %q", level)
+	}
+}
+
+// withEnvVar creates an Option that sets a single environment variable to a non-empty value.
+func withEnvVar(key, value string) Option {
+	return func(o *options) error {
+		if len(value) == 0 {
+			return fmt.Errorf("%s cannot be empty", key)
+		}
+		return WithEnv(key + "=" + value)(o)
+	}
+}
+
+// WithTimeout limits how long each vagrant command may run before it is terminated.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		o.timeout = timeout
+		return nil
+	}
+}
+
+// WithPollInterval sets how often helpers such as WaitForState query the status of machines. Defaults to 2 seconds.
+func WithPollInterval(interval time.Duration) Option {
+	return func(o *options) error {
+		if interval <= 0 {
+			return errors.New("poll interval must be positive")
+		}
+		o.pollInterval = interval
+		return nil
+	}
+}
+
+// WithDryRun enables dry-run mode: commands that would change machines, boxes or plugins are recorded in the given
+// DryRun instead of being run, and report success without any output. Read-only commands such as Status, Version,
+// PluginList or SSHConfig are still run, so helpers like EnsureRunning plan the commands they would actually issue.
+// Lifecycle and snapshot commands also record the names of the machines they target, resolving patterns and empty
+// targets with Status. Commands run with SSH are not considered read-only. A NativeSSH executor does not run vagrant
+// and is not affected.
+func WithDryRun(plan *DryRun) Option {
+	return func(o *options) error {
+		if plan == nil {
+			return errors.New("dry run cannot be nil")
+		}
+		o.dryRun = plan
+		return nil
+	}
+}
+
+// NewWithOptions creates a new Vagrant CLI wrapper targeting a directory where a Vagrantfile should exist. Unlike New,
+// it returns an error when the directory is empty or any of the options are invalid.
+func NewWithOptions(vagrantfileDir string, opts ...Option) (Vagrant, error) {
+	if len(vagrantfileDir) == 0 {
+		return nil, errors.New("vagrantfile dir cannot be empty")
+	}
+
+	o := &options{executable: binary}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.logger == nil {
+		o.logger = log.New()
+	}
+
+	switch r := o.runner.(type) {
+	case nil:
+		o.runner = command.ShellRunner{Dir: vagrantfileDir, Env: o.env}
+	case command.ShellRunner:
+		if len(r.Dir) == 0 {
+			r.Dir = vagrantfileDir
+		}
+		r.Env = append(r.Env, o.env...)
+		o.runner = r
+	default:
+		if len(o.env) > 0 {
+			return nil, errors.New("environment variables require a command.ShellRunner")
+		}
+	}
+
+	return wrapper{
+		executable:   o.executable,
+		logger:       o.logger,
+		runner:       o.runner,
+		timeout:      o.timeout,
+		pollInterval: o.pollInterval,
+		dryRun:       o.dryRun,
+	}, nil
+}
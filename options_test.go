@@ -0,0 +1,135 @@
+package vagrantexec
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dominodatalab/vagrant-exec/command"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		v, err := NewWithOptions("/some/path")
+		require.NoError(t, err)
+
+		w := v.(wrapper)
+		assert.Equal(t, "vagrant", w.executable)
+		assert.Equal(t, command.ShellRunner{Dir: "/some/path"}, w.runner)
+		assert.Equal(t, logrus.InfoLevel, w.logger.(*logrus.Logger).Level)
+		assert.Zero(t, w.timeout)
+	})
+
+	t.Run("options", func(t *testing.T) {
+		logger := logrus.New()
+		v, err := NewWithOptions("/some/path",
+			WithExecutable("/opt/vagrant/bin/vagrant"),
+			WithLogger(logger),
+			WithEnv("VAGRANT_HOME=/tmp/vagrant.d"),
+			WithEnv("VAGRANT_LOG=debug"),
+			WithTimeout(time.Minute),
+			WithPollInterval(time.Second),
+		)
+		require.NoError(t, err)
+
+		w := v.(wrapper)
+		assert.Equal(t, "/opt/vagrant/bin/vagrant", w.executable)
+		assert.Equal(t, logger, w.logger)
+		assert.Equal(t, time.Minute, w.timeout)
+		assert.Equal(t, time.Second, w.pollInterval)
+		assert.Equal(t, command.ShellRunner{
+			Dir: "/some/path",
+			Env: []string{"VAGRANT_HOME=/tmp/vagrant.d", "VAGRANT_LOG=debug"},
+		}, w.runner)
+	})
+
+	t.Run("custom_runner", func(t *testing.T) {
+		runner := new(mockRunner)
+		v, err := NewWithOptions("/some/path", WithRunner(runner))
+		require.NoError(t, err)
+		assert.Equal(t, runner, v.(wrapper).runner)
+
+		_, err = NewWithOptions("/some/path", WithRunner(runner), WithEnv("VAGRANT_LOG=debug"))
+		assert.EqualError(t, err, "environment variables require a command.ShellRunner")
+	})
+
+	t.Run("custom_shell_runner", func(t *testing.T) {
+		runner := command.ShellRunner{Env: []string{"VAGRANT_LOG=info"}}
+		v, err := NewWithOptions("/some/path", WithRunner(runner), WithEnv("VAGRANT_HOME=/tmp/vagrant.d"))
+		require.NoError(t, err)
+
+		assert.Equal(t, command.ShellRunner{
+			Dir: "/some/path",
+			Env: []string{"VAGRANT_LOG=info", "VAGRANT_HOME=/tmp/vagrant.d"},
+		}, v.(wrapper).runner)
+	})
+
+	t.Run("vagrant_env", func(t *testing.T) {
+		v, err := NewWithOptions("/some/path",
+			WithVagrantHome("/tmp/vagrant.d"),
+			WithVagrantCWD("/other/path"),
+			WithVagrantfileName("Vagrantfile.ci"),
+			WithDefaultProvider("libvirt"),
+			WithDotfilePath("/tmp/dotfile"),
+			WithVagrantLog("debug"),
+		)
+		require.NoError(t, err)
+
+		expected := []string{
+			"VAGRANT_HOME=/tmp/vagrant.d",
+			"VAGRANT_CWD=/other/path",
+			"VAGRANT_VAGRANTFILE=Vagrantfile.ci",
+			"VAGRANT_DEFAULT_PROVIDER=libvirt",
+			"VAGRANT_DOTFILE_PATH=/tmp/dotfile",
+			"VAGRANT_LOG=debug",
+		}
+		assert.Equal(t, expected, v.(wrapper).runner.(command.ShellRunner).Env)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		testcases := map[string]struct {
+			dir string
+			opt Option
+			err string
+		}{
+			"empty_dir":      {"", WithTimeout(time.Second), "vagrantfile dir cannot be empty"},
+			"empty_exec":     {".", WithExecutable(""), "executable cannot be empty"},
+			"nil_logger":     {".", WithLogger(nil), "logger cannot be nil"},
+			"nil_runner":     {".", WithRunner(nil), "runner cannot be nil"},
+			"bad_env":        {".", WithEnv("VAGRANT_HOME"), `invalid environment variable: "VAGRANT_HOME"`},
+			"empty_env_key":  {".", WithEnv("=value"), `invalid environment variable: "=value"`},
+			"zero_timeout":   {".", WithTimeout(0), "timeout must be positive"},
+			"negative_timer": {".", WithTimeout(-time.Second), "timeout must be positive"},
+			"zero_interval":  {".", WithPollInterval(0), "poll interval must be positive"},
+			"nil_dry_run":    {".", WithDryRun(nil), "dry run cannot be nil"},
+			"empty_home":     {".", WithVagrantHome(""), "VAGRANT_HOME cannot be empty"},
+			"bad_log_level":  {".", WithVagrantLog("trace"), `invalid vagrant log level: "trace"`},
+		}
+		for name, tc := range testcases {
+			t.Run(name, func(t *testing.T) {
+				_, err := NewWithOptions(tc.dir, tc.opt)
+				assert.EqualError(t, err, tc.err)
+			})
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "vagrant-exec")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		executable := filepath.Join(dir, "vagrant")
+		require.NoError(t, ioutil.WriteFile(executable, []byte("#!/bin/sh\nsleep 10\n"), 0755))
+
+		v, err := NewWithOptions(dir, WithExecutable(executable), WithTimeout(100*time.Millisecond))
+		require.NoError(t, err)
+
+		_, err = v.Version()
+		assert.IsType(t, command.CancelError{}, err)
+	})
+}
@@ -0,0 +1,70 @@
+package vagrantexec
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// ForwardedPort maps a port of a machine to the port on the host it is reachable on. Vagrant does not report the
+// transport protocol of forwarded ports, so TCP and UDP forwards of the same guest port cannot be told apart.
+type ForwardedPort struct {
+	Machine string
+	Guest   int
+	Host    int
+}
+
+// Port returns the ports forwarded from a machine to the host, after any collision was auto-corrected by Vagrant.
+// You can use an empty string as the nameOrID if you only have one VM defined in your Vagrantfile.
+func (w wrapper) Port(nameOrID string) ([]ForwardedPort, error) {
+	return w.PortContext(context.Background(), nameOrID)
+}
+
+// PortContext is like Port but includes a context.
+func (w wrapper) PortContext(ctx context.Context, nameOrID string) (ports []ForwardedPort, err error) {
+	out, err := w.exec(ctx, appendNameOrID([]string{"port", "--machine-readable"}, nameOrID)...)
+	if err != nil {
+		return
+	}
+	portInfo, err := parseMachineReadable(out)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range portInfo {
+		if entry.mType != "forwarded_port" || len(entry.data) < 2 {
+			continue
+		}
+
+		port := ForwardedPort{Machine: entry.target}
+		if port.Guest, err = strconv.Atoi(entry.data[0]); err != nil {
+			return nil, fmt.Errorf("invalid guest port %q: %v", entry.data[0], err)
+		}
+		if port.Host, err = strconv.Atoi(entry.data[1]); err != nil {
+			return nil, fmt.Errorf("invalid host port %q: %v", entry.data[1], err)
+		}
+		ports = append(ports, port)
+	}
+	return
+}
+
+// HostPortFor returns the host port that a port of a machine is forwarded to. When the guest port is forwarded for
+// several protocols, e.g. TCP and UDP, the first forward reported by Vagrant is returned.
+func (w wrapper) HostPortFor(nameOrID string, guestPort int) (int, error) {
+	return w.HostPortForContext(context.Background(), nameOrID, guestPort)
+}
+
+// HostPortForContext is like HostPortFor but includes a context.
+func (w wrapper) HostPortForContext(ctx context.Context, nameOrID string, guestPort int) (int, error) {
+	ports, err := w.PortContext(ctx, nameOrID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, p := range ports {
+		if p.Guest == guestPort {
+			return p.Host, nil
+		}
+	}
+	return 0, fmt.Errorf("guest port %d is not forwarded", guestPort)
+}
@@ -0,0 +1,74 @@
+package vagrantexec
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPort(t *testing.T) {
+	mockPort := mockedWrapperFn([]string{"port", "--machine-readable", "srv-1"})
+
+	t.Run("success", func(t *testing.T) {
+		w := mockPort(ioutil.ReadFile("testdata/port"))
+
+		ports, err := w.Port("srv-1")
+		require.NoError(t, err)
+		expected := []ForwardedPort{
+			{Machine: "srv-1", Guest: 22, Host: 2222},
+			{Machine: "srv-1", Guest: 8080, Host: 2200},
+		}
+		assert.Equal(t, expected, ports)
+	})
+
+	t.Run("single_machine", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"port", "--machine-readable"})(ioutil.ReadFile("testdata/port"))
+
+		ports, err := w.Port("")
+		require.NoError(t, err)
+		assert.Len(t, ports, 2)
+	})
+
+	t.Run("invalid_port", func(t *testing.T) {
+		w := mockPort([]byte("1563212501,srv-1,forwarded_port,http,8080"), nil)
+
+		_, err := w.Port("srv-1")
+		assert.Error(t, err)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockPort(nil, errors.New("runner error"))
+
+		_, err := w.Port("srv-1")
+		assert.Error(t, err)
+	})
+}
+
+func TestHostPortFor(t *testing.T) {
+	mockPort := mockedWrapperFn([]string{"port", "--machine-readable", "srv-1"})
+
+	t.Run("forwarded", func(t *testing.T) {
+		w := mockPort(ioutil.ReadFile("testdata/port"))
+
+		port, err := w.HostPortFor("srv-1", 8080)
+		require.NoError(t, err)
+		assert.Equal(t, 2200, port)
+	})
+
+	t.Run("not_forwarded", func(t *testing.T) {
+		w := mockPort(ioutil.ReadFile("testdata/port"))
+
+		_, err := w.HostPortFor("srv-1", 443)
+		assert.EqualError(t, err, "guest port 443 is not forwarded")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockPort(nil, errors.New("runner error"))
+
+		_, err := w.HostPortFor("srv-1", 8080)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,179 @@
+package vagrantexec
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Snapshot encapsulates the metadata of a machine snapshot.
+type Snapshot struct {
+	Name    string
+	Machine string
+}
+
+// SnapshotRestoreOptions controls what happens to a machine after a snapshot has been restored.
+type SnapshotRestoreOptions struct {
+	// NoProvision disables the provisioners that would otherwise run when the machine is started.
+	NoProvision bool
+	// NoStart leaves the machine stopped after the snapshot has been restored.
+	NoStart bool
+}
+
+// args returns the command-line flags for the options.
+func (o SnapshotRestoreOptions) args() (args []string) {
+	if o.NoProvision {
+		args = append(args, "--no-provision")
+	}
+	if o.NoStart {
+		args = append(args, "--no-start")
+	}
+	return
+}
+
+// SnapshotSave takes a snapshot of a machine and saves it with the given name. Vagrant only accepts a single machine,
+// so call it once per machine to snapshot several of them. An empty nameOrID snapshots every machine.
+func (w wrapper) SnapshotSave(name, nameOrID string) error {
+	return w.SnapshotSaveContext(context.Background(), name, nameOrID)
+}
+
+// SnapshotSaveContext is like SnapshotSave but includes a context.
+func (w wrapper) SnapshotSaveContext(ctx context.Context, name, nameOrID string) error {
+	if len(name) == 0 {
+		return errors.New("snapshot must have a name")
+	}
+
+	w, err := w.planTargets(ctx, appendNameOrID(nil, nameOrID))
+	if err != nil {
+		return err
+	}
+
+	w.logger.Infof("Saving vagrant snapshot: %s", name)
+	cmdArgs := appendNameOrID([]string{"snapshot", "save", "--machine-readable"}, nameOrID)
+	return w.execEvents(ctx, append(cmdArgs, name)...)
+}
+
+// SnapshotRestore restores a machine to the snapshot with the given name. An empty nameOrID restores every machine.
+func (w wrapper) SnapshotRestore(name string, opts SnapshotRestoreOptions, nameOrID string) error {
+	return w.SnapshotRestoreContext(context.Background(), name, opts, nameOrID)
+}
+
+// SnapshotRestoreContext is like SnapshotRestore but includes a context.
+func (w wrapper) SnapshotRestoreContext(ctx context.Context, name string, opts SnapshotRestoreOptions, nameOrID string) error {
+	if len(name) == 0 {
+		return errors.New("snapshot must have a name")
+	}
+
+	w, err := w.planTargets(ctx, appendNameOrID(nil, nameOrID))
+	if err != nil {
+		return err
+	}
+
+	w.logger.Infof("Restoring vagrant snapshot: %s", name)
+	cmdArgs := append([]string{"snapshot", "restore", "--machine-readable"}, opts.args()...)
+	cmdArgs = appendNameOrID(cmdArgs, nameOrID)
+	return w.execEvents(ctx, append(cmdArgs, name)...)
+}
+
+// SnapshotPush takes an unnamed snapshot of the target machines and pushes it onto the snapshot stack.
+func (w wrapper) SnapshotPush(targets ...string) error {
+	return w.SnapshotPushContext(context.Background(), targets...)
+}
+
+// SnapshotPushContext is like SnapshotPush but includes a context.
+func (w wrapper) SnapshotPushContext(ctx context.Context, targets ...string) error {
+	cmdArgs, err := appendTargets([]string{"snapshot", "push", "--machine-readable"}, targets)
+	if err != nil {
+		return err
+	}
+	if w, err = w.planTargets(ctx, targets); err != nil {
+		return err
+	}
+
+	w.logger.Info("Pushing vagrant snapshot")
+	return w.execEvents(ctx, cmdArgs...)
+}
+
+// SnapshotPop restores the target machines to the last pushed snapshot and removes it from the snapshot stack.
+func (w wrapper) SnapshotPop(opts SnapshotRestoreOptions, targets ...string) error {
+	return w.SnapshotPopContext(context.Background(), opts, targets...)
+}
+
+// SnapshotPopContext is like SnapshotPop but includes a context.
+func (w wrapper) SnapshotPopContext(ctx context.Context, opts SnapshotRestoreOptions, targets ...string) error {
+	cmdArgs, err := appendTargets(append([]string{"snapshot", "pop", "--machine-readable"}, opts.args()...), targets)
+	if err != nil {
+		return err
+	}
+	if w, err = w.planTargets(ctx, targets); err != nil {
+		return err
+	}
+
+	w.logger.Info("Popping vagrant snapshot")
+	return w.execEvents(ctx, cmdArgs...)
+}
+
+// SnapshotList returns the snapshots that have been taken of the target machines.
+func (w wrapper) SnapshotList(targets ...string) ([]Snapshot, error) {
+	return w.SnapshotListContext(context.Background(), targets...)
+}
+
+// SnapshotListContext is like SnapshotList but includes a context.
+func (w wrapper) SnapshotListContext(ctx context.Context, targets ...string) (snapshots []Snapshot, err error) {
+	cmdArgs, err := appendTargets([]string{"snapshot", "list", "--machine-readable"}, targets)
+	if err != nil {
+		return
+	}
+	out, err := w.exec(ctx, cmdArgs...)
+	if err != nil {
+		return
+	}
+	snapshotInfo, err := parseMachineReadable(out)
+	if err != nil {
+		return
+	}
+
+	noneTaken := map[string]bool{}
+	for _, entry := range snapshotInfo {
+		if entry.mType != "ui" || len(entry.target) == 0 || len(entry.data) < 2 {
+			continue
+		}
+
+		switch entry.data[0] {
+		case "output": // machines without snapshots explain why using "detail" rows
+			if strings.Contains(entry.data[1], "No snapshots have been taken yet") {
+				noneTaken[entry.target] = true
+			}
+		case "detail": // snapshot names are listed as "detail" rows
+			if !noneTaken[entry.target] {
+				snapshots = append(snapshots, Snapshot{
+					Name:    unescapeMachineData(entry.data[1]),
+					Machine: entry.target,
+				})
+			}
+		}
+	}
+	return
+}
+
+// SnapshotDelete deletes the snapshot with the given name from a machine. An empty nameOrID deletes the snapshot from
+// every machine.
+func (w wrapper) SnapshotDelete(name, nameOrID string) error {
+	return w.SnapshotDeleteContext(context.Background(), name, nameOrID)
+}
+
+// SnapshotDeleteContext is like SnapshotDelete but includes a context.
+func (w wrapper) SnapshotDeleteContext(ctx context.Context, name, nameOrID string) error {
+	if len(name) == 0 {
+		return errors.New("snapshot must have a name")
+	}
+
+	w, err := w.planTargets(ctx, appendNameOrID(nil, nameOrID))
+	if err != nil {
+		return err
+	}
+
+	w.logger.Infof("Deleting vagrant snapshot: %s", name)
+	cmdArgs := appendNameOrID([]string{"snapshot", "delete", "--machine-readable"}, nameOrID)
+	return w.execEvents(ctx, append(cmdArgs, name)...)
+}
@@ -0,0 +1,149 @@
+package vagrantexec
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotSave(t *testing.T) {
+	mockSave := mockedWrapperFn([]string{"snapshot", "save", "--machine-readable", "clean"})
+
+	t.Run("success", func(t *testing.T) {
+		w := mockSave(nil, nil)
+		assert.NoError(t, w.SnapshotSave("clean", ""))
+	})
+
+	t.Run("single_machine", func(t *testing.T) {
+		mockSave := mockedWrapperFn([]string{"snapshot", "save", "--machine-readable", "srv-1", "clean"})
+		w := mockSave(nil, nil)
+		assert.NoError(t, w.SnapshotSave("clean", "srv-1"))
+	})
+
+	t.Run("no_name", func(t *testing.T) {
+		w := mockSave(nil, nil)
+		assert.EqualError(t, w.SnapshotSave("", ""), "snapshot must have a name")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockSave(nil, errors.New("runner error"))
+		assert.Error(t, w.SnapshotSave("clean", ""))
+	})
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	mockRestore := mockedWrapperFn([]string{"snapshot", "restore", "--machine-readable", "clean"})
+
+	t.Run("success", func(t *testing.T) {
+		w := mockRestore(nil, nil)
+		assert.NoError(t, w.SnapshotRestore("clean", SnapshotRestoreOptions{}, ""))
+	})
+
+	t.Run("options", func(t *testing.T) {
+		mockRestore := mockedWrapperFn([]string{"snapshot", "restore", "--machine-readable", "--no-provision", "--no-start", "srv-1", "clean"})
+		w := mockRestore(nil, nil)
+
+		opts := SnapshotRestoreOptions{NoProvision: true, NoStart: true}
+		assert.NoError(t, w.SnapshotRestore("clean", opts, "srv-1"))
+	})
+
+	t.Run("no_name", func(t *testing.T) {
+		w := mockRestore(nil, nil)
+		assert.EqualError(t, w.SnapshotRestore("", SnapshotRestoreOptions{}, ""), "snapshot must have a name")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockRestore(nil, errors.New("runner error"))
+		assert.Error(t, w.SnapshotRestore("clean", SnapshotRestoreOptions{}, ""))
+	})
+}
+
+func TestSnapshotPush(t *testing.T) {
+	mockPush := mockedWrapperFn([]string{"snapshot", "push", "--machine-readable", "srv-1"})
+
+	t.Run("success", func(t *testing.T) {
+		w := mockPush(nil, nil)
+		assert.NoError(t, w.SnapshotPush("srv-1"))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockPush(nil, errors.New("runner error"))
+		assert.Error(t, w.SnapshotPush("srv-1"))
+	})
+}
+
+func TestSnapshotPop(t *testing.T) {
+	mockPop := mockedWrapperFn([]string{"snapshot", "pop", "--machine-readable", "--no-start", "srv-1"})
+
+	t.Run("success", func(t *testing.T) {
+		w := mockPop(nil, nil)
+		assert.NoError(t, w.SnapshotPop(SnapshotRestoreOptions{NoStart: true}, "srv-1"))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockPop(nil, errors.New("runner error"))
+		assert.Error(t, w.SnapshotPop(SnapshotRestoreOptions{NoStart: true}, "srv-1"))
+	})
+}
+
+func TestSnapshotList(t *testing.T) {
+	mockList := mockedWrapperFn([]string{"snapshot", "list", "--machine-readable"})
+
+	t.Run("with_snapshots", func(t *testing.T) {
+		w := mockList(ioutil.ReadFile("testdata/snapshot-list"))
+
+		actual, err := w.SnapshotList()
+		require.NoError(t, err)
+
+		expected := []Snapshot{
+			{Name: "clean", Machine: "srv-1"},
+			{Name: "provisioned", Machine: "srv-1"},
+			{Name: "before upgrade, v2", Machine: "srv-1"},
+		}
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("no_snapshots", func(t *testing.T) {
+		mockList := mockedWrapperFn([]string{"snapshot", "list", "--machine-readable", "srv-1"})
+		w := mockList(ioutil.ReadFile("testdata/snapshot-list-none"))
+
+		actual, err := w.SnapshotList("srv-1")
+		require.NoError(t, err)
+		assert.Empty(t, actual)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockList(nil, errors.New("runner error"))
+
+		_, err := w.SnapshotList()
+		assert.Error(t, err)
+	})
+}
+
+func TestSnapshotDelete(t *testing.T) {
+	mockDelete := mockedWrapperFn([]string{"snapshot", "delete", "--machine-readable", "srv-2", "clean"})
+
+	t.Run("success", func(t *testing.T) {
+		w := mockDelete(nil, nil)
+		assert.NoError(t, w.SnapshotDelete("clean", "srv-2"))
+	})
+
+	t.Run("empty_target", func(t *testing.T) {
+		mockDelete := mockedWrapperFn([]string{"snapshot", "delete", "--machine-readable", "clean"})
+		w := mockDelete(nil, nil)
+		assert.NoError(t, w.SnapshotDelete("clean", ""))
+	})
+
+	t.Run("no_name", func(t *testing.T) {
+		w := mockDelete(nil, nil)
+		assert.EqualError(t, w.SnapshotDelete("", "srv-2"), "snapshot must have a name")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockDelete(nil, errors.New("runner error"))
+		assert.Error(t, w.SnapshotDelete("clean", "srv-2"))
+	})
+}
@@ -0,0 +1,151 @@
+package vagrantexec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SSHConfig contains the OpenSSH settings required to connect to a machine, as reported by `vagrant ssh-config`.
+type SSHConfig struct {
+	// Host is the alias of the machine, i.e. its name.
+	Host         string
+	HostName     string
+	User         string
+	Port         int
+	IdentityFile []string
+	ProxyCommand string
+	// Options contains every other setting keyed by its OpenSSH name, e.g. "StrictHostKeyChecking".
+	Options map[string]string
+}
+
+// SSHConfig returns the SSH settings of the target machines.
+func (w wrapper) SSHConfig(targets ...string) ([]SSHConfig, error) {
+	return w.SSHConfigContext(context.Background(), targets...)
+}
+
+// SSHConfigContext is like SSHConfig but includes a context.
+func (w wrapper) SSHConfigContext(ctx context.Context, targets ...string) ([]SSHConfig, error) {
+	cmdArgs, err := appendTargets([]string{"ssh-config"}, targets)
+	if err != nil {
+		return nil, err
+	}
+	out, err := w.exec(ctx, cmdArgs...)
+	if err != nil {
+		return nil, err
+	}
+	return parseSSHConfig(out)
+}
+
+// WriteSSHConfig writes the settings of the given machines in the OpenSSH config file format, so they can be used with
+// `ssh -F`.
+func WriteSSHConfig(w io.Writer, configs ...SSHConfig) error {
+	var buf bytes.Buffer
+	for _, c := range configs {
+		fmt.Fprintf(&buf, "Host %s\n", c.Host)
+		for _, setting := range sshSettings(c) {
+			fmt.Fprintf(&buf, "  %s %s\n", setting[0], setting[1])
+		}
+		buf.WriteString("\n")
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// parseSSHConfig converts the output of `vagrant ssh-config` into a slice of SSHConfig.
+func parseSSHConfig(out []byte) (configs []SSHConfig, err error) {
+	var config *SSHConfig
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid ssh-config line: %s", line)
+		}
+		key, value := line[:i], strings.TrimSpace(line[i:])
+
+		if strings.EqualFold(key, "Host") { // each machine starts with its host alias
+			configs = append(configs, SSHConfig{Host: value, Options: map[string]string{}})
+			config = &configs[len(configs)-1]
+			continue
+		}
+		if config == nil {
+			return nil, fmt.Errorf("ssh-config setting outside of a host: %s", line)
+		}
+
+		switch strings.ToLower(key) {
+		case "hostname":
+			config.HostName = value
+		case "user":
+			config.User = value
+		case "port":
+			if config.Port, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid ssh-config port: %s", value)
+			}
+		case "identityfile":
+			config.IdentityFile = append(config.IdentityFile, strings.Trim(value, `"`))
+		case "proxycommand":
+			config.ProxyCommand = value
+		default:
+			config.Options[key] = value
+		}
+	}
+	return configs, scanner.Err()
+}
+
+// sshOptionArgs converts the settings of a machine into `-o` flags, so ssh and scp can connect to it without a config
+// file.
+func sshOptionArgs(c SSHConfig) []string {
+	var args []string
+	for _, setting := range sshSettings(c) {
+		args = append(args, "-o", setting[0]+"="+setting[1])
+	}
+	return args
+}
+
+// sshSettings returns the key and value of every setting of a machine that has a value, in a stable order.
+func sshSettings(c SSHConfig) (settings [][2]string) {
+	add := func(key, value string) {
+		if len(value) > 0 {
+			settings = append(settings, [2]string{key, value})
+		}
+	}
+
+	add("HostName", c.HostName)
+	add("User", c.User)
+	if c.Port > 0 {
+		add("Port", strconv.Itoa(c.Port))
+	}
+	for _, f := range c.IdentityFile {
+		add("IdentityFile", quoteSSHValue(f))
+	}
+	add("ProxyCommand", c.ProxyCommand)
+
+	keys := make([]string, 0, len(c.Options))
+	for k := range c.Options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		add(k, c.Options[k])
+	}
+	return settings
+}
+
+// quoteSSHValue wraps values containing whitespace in double quotes.
+func quoteSSHValue(value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return `"` + value + `"`
+	}
+	return value
+}
@@ -0,0 +1,129 @@
+package vagrantexec
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sshConfigFixture = []SSHConfig{
+	{
+		Host:     "srv-1",
+		HostName: "127.0.0.1",
+		User:     "vagrant",
+		Port:     2222,
+		IdentityFile: []string{
+			"/home/dev/project/.vagrant/machines/srv-1/virtualbox/private_key",
+			"/home/dev/.vagrant.d/insecure private key",
+		},
+		Options: map[string]string{
+			"UserKnownHostsFile":     "/dev/null",
+			"StrictHostKeyChecking":  "no",
+			"PasswordAuthentication": "no",
+			"IdentitiesOnly":         "yes",
+			"LogLevel":               "FATAL",
+		},
+	},
+	{
+		Host:         "srv-2",
+		HostName:     "192.168.121.45",
+		User:         "vagrant",
+		Port:         22,
+		IdentityFile: []string{"/home/dev/project/.vagrant/machines/srv-2/libvirt/private_key"},
+		ProxyCommand: "ssh 'libvirt-host' -l 'root' -i '/root/.ssh/id_rsa' nc %h %p",
+		Options: map[string]string{
+			"UserKnownHostsFile":     "/dev/null",
+			"StrictHostKeyChecking":  "no",
+			"PasswordAuthentication": "no",
+			"IdentitiesOnly":         "yes",
+			"LogLevel":               "FATAL",
+		},
+	},
+}
+
+func TestSSHConfig(t *testing.T) {
+	mockSSHConfig := mockedWrapperFn([]string{"ssh-config"})
+
+	t.Run("multi_machine", func(t *testing.T) {
+		w := mockSSHConfig(ioutil.ReadFile("testdata/ssh-config"))
+
+		configs, err := w.SSHConfig()
+		require.NoError(t, err)
+		assert.Equal(t, sshConfigFixture, configs)
+	})
+
+	t.Run("target", func(t *testing.T) {
+		mockSSHConfig := mockedWrapperFn([]string{"ssh-config", "srv-2"})
+		w := mockSSHConfig([]byte("Host srv-2\n  HostName 10.0.0.2\n  Port 22\n"), nil)
+
+		configs, err := w.SSHConfig("srv-2")
+		require.NoError(t, err)
+		require.Len(t, configs, 1)
+		assert.Equal(t, "10.0.0.2", configs[0].HostName)
+	})
+
+	t.Run("bad_output", func(t *testing.T) {
+		testcases := map[string]string{
+			"no_host":  "  HostName 127.0.0.1\n",
+			"no_value": "Host srv-1\n  HostName\n",
+			"bad_port": "Host srv-1\n  Port twenty-two\n",
+		}
+		for name, out := range testcases {
+			t.Run(name, func(t *testing.T) {
+				w := mockSSHConfig([]byte(out), nil)
+
+				_, err := w.SSHConfig()
+				assert.Error(t, err)
+			})
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockSSHConfig(nil, errors.New("runner error"))
+
+		_, err := w.SSHConfig()
+		assert.Error(t, err)
+	})
+}
+
+func TestWriteSSHConfig(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteSSHConfig(&buf, sshConfigFixture...))
+
+	expected := `Host srv-1
+  HostName 127.0.0.1
+  User vagrant
+  Port 2222
+  IdentityFile /home/dev/project/.vagrant/machines/srv-1/virtualbox/private_key
+  IdentityFile "/home/dev/.vagrant.d/insecure private key"
+  IdentitiesOnly yes
+  LogLevel FATAL
+  PasswordAuthentication no
+  StrictHostKeyChecking no
+  UserKnownHostsFile /dev/null
+
+Host srv-2
+  HostName 192.168.121.45
+  User vagrant
+  Port 22
+  IdentityFile /home/dev/project/.vagrant/machines/srv-2/libvirt/private_key
+  ProxyCommand ssh 'libvirt-host' -l 'root' -i '/root/.ssh/id_rsa' nc %h %p
+  IdentitiesOnly yes
+  LogLevel FATAL
+  PasswordAuthentication no
+  StrictHostKeyChecking no
+  UserKnownHostsFile /dev/null
+
+`
+	assert.Equal(t, expected, buf.String())
+
+	t.Run("round_trip", func(t *testing.T) {
+		configs, err := parseSSHConfig(buf.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, sshConfigFixture, configs)
+	})
+}
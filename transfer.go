@@ -0,0 +1,117 @@
+package vagrantexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// scpBinary is the executable used to copy files from machines.
+const scpBinary = "scp"
+
+// Upload copies a file or directory from the host to a machine using `vagrant upload`. The source may be a glob
+// pattern, in which case every match is copied into the destination directory. When compress is true, the files are
+// compressed before being uploaded, which is faster for directories containing many files. Relative host paths are
+// resolved against the current working directory.
+// You can use an empty string as the nameOrID if you only have one VM defined in your Vagrantfile.
+func (w wrapper) Upload(nameOrID, src, dst string, compress bool) error {
+	return w.UploadContext(context.Background(), nameOrID, src, dst, compress)
+}
+
+// UploadContext is like Upload but includes a context.
+func (w wrapper) UploadContext(ctx context.Context, nameOrID, src, dst string, compress bool) error {
+	if len(src) == 0 || len(dst) == 0 {
+		return errors.New("upload requires a source and a destination")
+	}
+
+	sources := []string{src}
+	if isGlob(src) {
+		matches, err := filepath.Glob(src)
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("no files match %s", src)
+		}
+		sources = matches
+	}
+
+	for _, source := range sources {
+		source, err := filepath.Abs(source)
+		if err != nil {
+			return err
+		}
+		destination := dst
+		if isGlob(src) {
+			destination = path.Join(dst, filepath.Base(source))
+		}
+
+		cmdArgs := []string{"upload", "--machine-readable"}
+		if compress {
+			cmdArgs = append(cmdArgs, "--compress")
+		}
+		cmdArgs = append(cmdArgs, source, destination)
+		if len(nameOrID) > 0 {
+			cmdArgs = append(cmdArgs, nameOrID)
+		}
+
+		w.logger.Infof("Uploading %s to %s", source, destination)
+		if err := w.execEvents(ctx, cmdArgs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Download copies a file or directory from a machine to the host using scp and the settings reported by
+// `vagrant ssh-config`. Directories are copied recursively and the source may be a glob pattern that is expanded on the
+// guest, in which case the destination must be an existing directory. Relative host paths are resolved against the
+// current working directory.
+// You can use an empty string as the nameOrID if you only have one VM defined in your Vagrantfile.
+func (w wrapper) Download(nameOrID, src, dst string) error {
+	return w.DownloadContext(context.Background(), nameOrID, src, dst)
+}
+
+// DownloadContext is like Download but includes a context.
+func (w wrapper) DownloadContext(ctx context.Context, nameOrID, src, dst string) (err error) {
+	if len(src) == 0 || len(dst) == 0 {
+		return errors.New("download requires a source and a destination")
+	}
+	if dst, err = filepath.Abs(dst); err != nil {
+		return err
+	}
+
+	configs, err := w.SSHConfigContext(ctx, appendNameOrID(nil, nameOrID)...)
+	if err != nil {
+		return err
+	}
+	if len(configs) != 1 {
+		return fmt.Errorf("expected ssh-config for a single machine, got %d", len(configs))
+	}
+
+	ctx, cancel := w.commandContext(ctx)
+	defer cancel()
+
+	// the settings are passed as flags rather than through a config file, so the command is self-contained and can be
+	// replayed from a dry-run plan; the user's own config is ignored like it would be with `-F`
+	cmdArgs := append([]string{"-F", os.DevNull}, sshOptionArgs(configs[0])...)
+	cmdArgs = append(cmdArgs, "-r", "-q", fmt.Sprintf("%s:%s", configs[0].Host, src), dst)
+	fullCmd := fmt.Sprintf("%s %s", scpBinary, strings.Join(cmdArgs, " "))
+
+	w.logger.Infof("Downloading %s to %s", src, dst)
+	if w.skip(scpBinary, cmdArgs) {
+		return nil
+	}
+	w.logger.Debugf("Running command [%s]", fullCmd)
+	_, err = w.runner.ExecuteContext(ctx, scpBinary, cmdArgs...)
+	return err
+}
+
+// isGlob returns true if the path contains any of the special characters used by filepath.Match.
+func isGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
@@ -0,0 +1,111 @@
+package vagrantexec
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vagrant-exec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a.log", "b.log", "c.txt"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644))
+	}
+	src := filepath.Join(dir, "c.txt")
+
+	t.Run("success", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"upload", "--machine-readable", src, "/tmp/c.txt"})(nil, nil)
+		assert.NoError(t, w.Upload("", src, "/tmp/c.txt", false))
+	})
+
+	t.Run("compressed_target", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"upload", "--machine-readable", "--compress", dir, "/tmp/logs", "srv-1"})(nil, nil)
+		assert.NoError(t, w.Upload("srv-1", dir, "/tmp/logs", true))
+	})
+
+	t.Run("glob", func(t *testing.T) {
+		runner := new(mockRunner)
+		runner.On("Execute", "vagrant", []string{"upload", "--machine-readable", filepath.Join(dir, "a.log"), "/tmp/logs/a.log", "srv-1"}).Return(nil, nil).Once()
+		runner.On("Execute", "vagrant", []string{"upload", "--machine-readable", filepath.Join(dir, "b.log"), "/tmp/logs/b.log", "srv-1"}).Return(nil, nil).Once()
+
+		assert.NoError(t, mockedWrapper(runner).Upload("srv-1", filepath.Join(dir, "*.log"), "/tmp/logs", false))
+		runner.AssertExpectations(t)
+	})
+
+	t.Run("no_match", func(t *testing.T) {
+		w := mockedWrapper(new(mockRunner))
+		assert.EqualError(t, w.Upload("", filepath.Join(dir, "*.zip"), "/tmp", false), "no files match "+filepath.Join(dir, "*.zip"))
+	})
+
+	t.Run("missing_paths", func(t *testing.T) {
+		w := mockedWrapper(new(mockRunner))
+		assert.EqualError(t, w.Upload("", "", "/tmp", false), "upload requires a source and a destination")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"upload", "--machine-readable", src, "/tmp/c.txt"})(nil, errors.New("runner error"))
+		assert.Error(t, w.Upload("", src, "/tmp/c.txt", false))
+	})
+}
+
+func TestDownload(t *testing.T) {
+	dst, err := filepath.Abs("artifacts")
+	require.NoError(t, err)
+
+	sshConfig := []byte("Host srv-1\n  HostName 127.0.0.1\n  User vagrant\n  Port 2222\n")
+
+	scpArgs := func(src string) []string {
+		return []string{
+			"-F", os.DevNull, "-o", "HostName=127.0.0.1", "-o", "User=vagrant", "-o", "Port=2222",
+			"-r", "-q", "srv-1:" + src, dst,
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		runner := new(mockRunner)
+		runner.On("Execute", "vagrant", []string{"ssh-config", "srv-1"}).Return(sshConfig, nil)
+		runner.On("Execute", "scp", scpArgs("/var/log/*.log")).Return(nil, nil)
+
+		assert.NoError(t, mockedWrapper(runner).Download("srv-1", "/var/log/*.log", "artifacts"))
+		runner.AssertExpectations(t)
+	})
+
+	t.Run("multiple_machines", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"ssh-config"})(ioutil.ReadFile("testdata/ssh-config"))
+		assert.EqualError(t, w.Download("", "/tmp/file", "file"), "expected ssh-config for a single machine, got 2")
+	})
+
+	t.Run("missing_paths", func(t *testing.T) {
+		w := mockedWrapper(new(mockRunner))
+		assert.EqualError(t, w.Download("srv-1", "/tmp/file", ""), "download requires a source and a destination")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		runner := new(mockRunner)
+		runner.On("Execute", "vagrant", []string{"ssh-config", "srv-1"}).Return(sshConfig, nil)
+		runner.On("Execute", "scp", scpArgs("/tmp/file")).Return(nil, errors.New("runner error"))
+
+		assert.EqualError(t, mockedWrapper(runner).Download("srv-1", "/tmp/file", "artifacts"), "runner error")
+	})
+
+	t.Run("dry_run", func(t *testing.T) {
+		runner := new(mockRunner)
+		runner.On("Execute", "vagrant", []string{"ssh-config", "srv-1"}).Return(sshConfig, nil)
+		plan := new(DryRun)
+
+		w := mockedWrapper(runner)
+		w.dryRun = plan
+
+		require.NoError(t, w.Download("srv-1", "/tmp/file", "artifacts"))
+		assert.Equal(t, []PlannedCommand{{Executable: "scp", Args: scpArgs("/tmp/file")}}, plan.Commands())
+		runner.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,80 @@
+package vagrantexec
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dominodatalab/vagrant-exec/command"
+)
+
+// VagrantError is created whenever Vagrant exits with a non-zero status after reporting the cause in its
+// machine-readable output. It wraps the command.ExitError returned by the runner.
+type VagrantError struct {
+	errorClass string
+	message    string
+	exitStatus int
+	machine    string
+	err        error
+}
+
+func (e VagrantError) Error() string {
+	return fmt.Sprintf("%s: %s", e.errorClass, e.message)
+}
+
+// ErrorClass returns the Ruby class of the error, e.g. "Vagrant::Errors::VagrantfileSyntaxError".
+func (e VagrantError) ErrorClass() string {
+	return e.errorClass
+}
+
+// Message returns the human-readable error message.
+func (e VagrantError) Message() string {
+	return e.message
+}
+
+// ExitStatus returns the exit code of the vagrant process.
+func (e VagrantError) ExitStatus() int {
+	return e.exitStatus
+}
+
+// Machine returns the name of the machine that caused the error. It is empty for environment-wide errors.
+func (e VagrantError) Machine() string {
+	return e.machine
+}
+
+// Unwrap returns the underlying command.ExitError.
+func (e VagrantError) Unwrap() error {
+	return e.err
+}
+
+// newVagrantError creates a VagrantError from an error-exit event and the error returned by the runner.
+func newVagrantError(event ErrorExitEvent, ee command.ExitError) VagrantError {
+	return VagrantError{
+		errorClass: event.ErrorClass,
+		message:    event.Message,
+		exitStatus: ee.ExitStatus(),
+		machine:    event.Target,
+		err:        ee,
+	}
+}
+
+// wrapVagrantError converts an ExitError into a VagrantError when the machine-readable output of the command contains
+// an error-exit record. Any other error is returned as-is.
+func wrapVagrantError(machineOut []byte, err error) error {
+	ee, ok := err.(command.ExitError)
+	if !ok {
+		return err
+	}
+
+	var exitEvent *ErrorExitEvent
+	for _, line := range strings.Split(string(machineOut), "\n") {
+		if entry, perr := parseMachineReadableLine(line); perr == nil && entry.mType == "error-exit" {
+			if event, ok := newEvent(entry).(ErrorExitEvent); ok {
+				exitEvent = &event
+			}
+		}
+	}
+	if exitEvent == nil {
+		return err
+	}
+	return newVagrantError(*exitEvent, ee)
+}
@@ -0,0 +1,37 @@
+package vagrantexec
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dominodatalab/vagrant-exec/command"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapVagrantError(t *testing.T) {
+	exitErr := command.NewExitError("vagrant", 1, "boom")
+	out := []byte("1562175813,,ui,error,boom\n1562175813,srv-1,error-exit,Vagrant::Errors::VBoxManageError,boom")
+
+	t.Run("error_exit", func(t *testing.T) {
+		err := wrapVagrantError(out, exitErr)
+		require.IsType(t, VagrantError{}, err)
+
+		ve := err.(VagrantError)
+		assert.Equal(t, "Vagrant::Errors::VBoxManageError", ve.ErrorClass())
+		assert.Equal(t, "boom", ve.Message())
+		assert.Equal(t, 1, ve.ExitStatus())
+		assert.Equal(t, "srv-1", ve.Machine())
+		assert.Equal(t, "Vagrant::Errors::VBoxManageError: boom", ve.Error())
+		assert.Equal(t, exitErr, errors.Unwrap(ve))
+	})
+
+	t.Run("no_error_exit", func(t *testing.T) {
+		assert.Equal(t, exitErr, wrapVagrantError([]byte("1562175813,,ui,error,boom"), exitErr))
+	})
+
+	t.Run("not_exit_error", func(t *testing.T) {
+		err := errors.New("runner error")
+		assert.Equal(t, err, wrapVagrantError(out, err))
+	})
+}
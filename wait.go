@@ -0,0 +1,71 @@
+package vagrantexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dominodatalab/vagrant-exec/command"
+)
+
+// defaultPollInterval is the time between status queries when no interval was configured with WithPollInterval.
+const defaultPollInterval = 2 * time.Second
+
+// WaitForState polls the status of a machine until it reaches one of the desired states and returns its last status.
+// It fails fast when the machine ends up in a state it cannot leave on its own, i.e. Stuck or Inaccessible, unless that
+// state is desired. A command.CancelError is returned when the context is done before the machine reaches a desired
+// state.
+// You can use an empty string as the nameOrID if you only have one VM defined in your Vagrantfile.
+func (w wrapper) WaitForState(ctx context.Context, nameOrID string, desired ...MachineState) (status MachineStatus, err error) {
+	if len(desired) == 0 {
+		err = errors.New("at least one desired state is required")
+		return
+	}
+
+	interval := w.statusPollInterval()
+	for {
+		statuses, err := w.StatusContext(ctx, appendNameOrID(nil, nameOrID)...)
+		if err != nil {
+			return status, err
+		}
+		if len(statuses) != 1 {
+			return status, fmt.Errorf("expected status for a single machine, got %d", len(statuses))
+		}
+
+		status = statuses[0]
+		if containsState(desired, status.State) {
+			return status, nil
+		}
+		if containsState(terminalStates, status.State) {
+			return status, fmt.Errorf("machine %s is %s", status.Name, status.State)
+		}
+		w.logger.Debugf("Waiting for machine %s to leave state %s", status.Name, status.State)
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return status, command.NewCancelError(w.executable, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// statusPollInterval returns the configured time between status queries.
+func (w wrapper) statusPollInterval() time.Duration {
+	if w.pollInterval > 0 {
+		return w.pollInterval
+	}
+	return defaultPollInterval
+}
+
+// containsState returns true if the state is part of the list.
+func containsState(states []MachineState, state MachineState) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
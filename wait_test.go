@@ -0,0 +1,103 @@
+package vagrantexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/dominodatalab/vagrant-exec/command"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusOutput returns the machine-readable status output of a single machine in the given state.
+func statusOutput(name, state string) []byte {
+	return []byte(fmt.Sprintf("1562175814,%[1]s,provider-name,virtualbox\n1562175814,%[1]s,state,%[2]s\n", name, state))
+}
+
+func TestWaitForState(t *testing.T) {
+	statusArgs := []string{"status", "--machine-readable", "srv-1"}
+
+	// mockStates creates a wrapper reporting each of the given states in turn, and the last one thereafter.
+	mockStates := func(states ...string) (wrapper, *mockRunner) {
+		runner := new(mockRunner)
+		for i, state := range states {
+			call := runner.On("Execute", "vagrant", statusArgs).Return(statusOutput("srv-1", state), nil)
+			if i < len(states)-1 {
+				call.Once()
+			}
+		}
+
+		w := mockedWrapper(runner)
+		w.pollInterval = time.Millisecond
+		return w, runner
+	}
+
+	t.Run("reached", func(t *testing.T) {
+		w, runner := mockStates("running", "stopping", "poweroff")
+
+		status, err := w.WaitForState(context.Background(), "srv-1", PowerOff, Aborted)
+		require.NoError(t, err)
+		assert.Equal(t, MachineStatus{Name: "srv-1", Provider: "virtualbox", State: PowerOff}, status)
+		runner.AssertNumberOfCalls(t, "Execute", 3)
+	})
+
+	t.Run("already_reached", func(t *testing.T) {
+		w, runner := mockStates("running")
+
+		_, err := w.WaitForState(context.Background(), "srv-1", Running)
+		require.NoError(t, err)
+		runner.AssertNumberOfCalls(t, "Execute", 1)
+	})
+
+	t.Run("terminal_state", func(t *testing.T) {
+		w, _ := mockStates("stopping", "stuck")
+
+		status, err := w.WaitForState(context.Background(), "srv-1", PowerOff)
+		assert.EqualError(t, err, "machine srv-1 is Stuck")
+		assert.Equal(t, Stuck, status.State)
+	})
+
+	t.Run("terminal_state_desired", func(t *testing.T) {
+		w, _ := mockStates("inaccessible")
+
+		_, err := w.WaitForState(context.Background(), "srv-1", Inaccessible)
+		assert.NoError(t, err)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		w, _ := mockStates("running")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		status, err := w.WaitForState(ctx, "srv-1", PowerOff)
+		require.IsType(t, command.CancelError{}, err)
+		assert.Equal(t, context.DeadlineExceeded, err.(command.CancelError).Cause())
+		assert.Equal(t, Running, status.State)
+	})
+
+	t.Run("multiple_machines", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"status", "--machine-readable"})(ioutil.ReadFile("testdata/status-multiple"))
+
+		_, err := w.WaitForState(context.Background(), "", Running)
+		assert.EqualError(t, err, "expected status for a single machine, got 2")
+	})
+
+	t.Run("no_desired_states", func(t *testing.T) {
+		w, _ := mockStates("running")
+
+		_, err := w.WaitForState(context.Background(), "srv-1")
+		assert.EqualError(t, err, "at least one desired state is required")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockedWrapperFn(statusArgs)(nil, errors.New("runner error"))
+
+		_, err := w.WaitForState(context.Background(), "srv-1", Running)
+		assert.EqualError(t, err, "runner error")
+	})
+}
@@ -0,0 +1,87 @@
+package vagrantexec
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// StateChange describes a machine moving from one state to another. Machines that appear are reported as moving from
+// Unknown, and machines that disappear, e.g. because they were removed from the Vagrantfile, as moving to Unknown.
+type StateChange struct {
+	Machine string
+	From    MachineState
+	To      MachineState
+	// At is the time the change was observed.
+	At time.Time
+}
+
+// Watch queries the status of the target machines at the given interval and sends a StateChange on the returned
+// channel whenever the state of a machine changes. The current state of every machine is reported as a change from
+// Unknown first. The interval configured with WithPollInterval is used when the given interval is not positive.
+//
+// Failed status queries are logged and retried at the next interval. The channel is closed once the context is done.
+func (w wrapper) Watch(ctx context.Context, interval time.Duration, targets ...string) <-chan StateChange {
+	if interval <= 0 {
+		interval = w.statusPollInterval()
+	}
+
+	changes := make(chan StateChange)
+	go func() {
+		defer close(changes)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		states := map[string]MachineState{}
+		for {
+			statuses, err := w.StatusContext(ctx, targets...)
+			if err != nil && ctx.Err() == nil {
+				w.logger.Warnf("Cannot watch machine states: %v", err)
+			}
+			if err == nil {
+				for _, change := range diffStates(states, statuses, time.Now()) {
+					select {
+					case changes <- change:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return changes
+}
+
+// diffStates updates the known states of machines with their current status and returns the changes sorted by machine
+// name.
+func diffStates(states map[string]MachineState, statuses []MachineStatus, at time.Time) (changes []StateChange) {
+	seen := map[string]bool{}
+	for _, status := range statuses {
+		seen[status.Name] = true
+
+		from := states[status.Name] // machines that were not seen before are Unknown
+		if from != status.State {
+			changes = append(changes, StateChange{Machine: status.Name, From: from, To: status.State, At: at})
+		}
+		states[status.Name] = status.State
+	}
+	for name, from := range states {
+		if seen[name] {
+			continue
+		}
+		if from != Unknown {
+			changes = append(changes, StateChange{Machine: name, From: from, To: Unknown, At: at})
+		}
+		delete(states, name)
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Machine < changes[j].Machine })
+	return
+}
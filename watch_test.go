@@ -0,0 +1,66 @@
+package vagrantexec
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatch(t *testing.T) {
+	statusArgs := []string{"status", "--machine-readable"}
+	join := func(outputs ...[]byte) (out []byte) {
+		for _, o := range outputs {
+			out = append(out, o...)
+		}
+		return
+	}
+
+	runner := new(mockRunner)
+	runner.On("Execute", "vagrant", statusArgs).Return(join(statusOutput("srv-1", "running"), statusOutput("srv-2", "running")), nil).Once()
+	runner.On("Execute", "vagrant", statusArgs).Return(nil, errors.New("runner error")).Once()
+	runner.On("Execute", "vagrant", statusArgs).Return(join(statusOutput("srv-1", "aborted"), statusOutput("srv-2", "running")), nil).Once()
+	runner.On("Execute", "vagrant", statusArgs).Return(statusOutput("srv-1", "aborted"), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var changes []StateChange
+	for change := range mockedWrapper(runner).Watch(ctx, time.Millisecond) {
+		assert.False(t, change.At.IsZero())
+		change.At = time.Time{}
+
+		changes = append(changes, change)
+		if len(changes) == 4 {
+			cancel()
+		}
+	}
+
+	expected := []StateChange{
+		{Machine: "srv-1", From: Unknown, To: Running},
+		{Machine: "srv-2", From: Unknown, To: Running},
+		{Machine: "srv-1", From: Running, To: Aborted},
+		{Machine: "srv-2", From: Running, To: Unknown},
+	}
+	assert.Equal(t, expected, changes)
+}
+
+func TestDiffStates(t *testing.T) {
+	at := time.Now()
+	states := map[string]MachineState{"srv-1": Running, "srv-2": PowerOff}
+
+	changes := diffStates(states, []MachineStatus{
+		{Name: "srv-3", State: Unknown},
+		{Name: "srv-2", State: PowerOff},
+		{Name: "srv-4", State: NotCreated},
+	}, at)
+
+	expected := []StateChange{
+		{Machine: "srv-1", From: Running, To: Unknown, At: at},
+		{Machine: "srv-4", From: Unknown, To: NotCreated, At: at},
+	}
+	assert.Equal(t, expected, changes)
+	assert.Equal(t, map[string]MachineState{"srv-2": PowerOff, "srv-3": Unknown, "srv-4": NotCreated}, states)
+}
@@ -2,10 +2,15 @@
 package vagrantexec
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dominodatalab/vagrant-exec/command"
 	log "github.com/sirupsen/logrus"
@@ -14,21 +19,118 @@ import (
 const binary = "vagrant"
 
 // Vagrant defines the interface for executing Vagrant commands.
+//
+// Lifecycle methods accept an optional list of targets, each one being a machine name, ID or a regular expression
+// wrapped in slashes (see TargetPattern). All machines are targeted when none are given, and an error is returned for
+// empty targets so that a missing name never acts on every machine.
+//
+// Every method has a Context variant that terminates the underlying vagrant process when the context is done. In that
+// case, a command.CancelError is returned. Commands run with a cancellable context do not receive the signals sent by
+// the terminal, so cancel the context on Ctrl-C instead, e.g. with signal.NotifyContext.
 type Vagrant interface {
-	Up() error
-	Halt() error
-	Destroy() error
-	Status() (statusList []MachineStatus, err error)
+	Up(targets ...string) error
+	UpContext(ctx context.Context, targets ...string) error
+	UpWithOptions(opts UpOptions, targets ...string) error
+	UpWithOptionsContext(ctx context.Context, opts UpOptions, targets ...string) error
+	Halt(targets ...string) error
+	HaltContext(ctx context.Context, targets ...string) error
+	Destroy(targets ...string) error
+	DestroyContext(ctx context.Context, targets ...string) error
+	Suspend(targets ...string) error
+	SuspendContext(ctx context.Context, targets ...string) error
+	Resume(targets ...string) error
+	ResumeContext(ctx context.Context, targets ...string) error
+	Reload(opts ReloadOptions, targets ...string) error
+	ReloadContext(ctx context.Context, opts ReloadOptions, targets ...string) error
+	Provision(opts ProvisionOptions, targets ...string) error
+	ProvisionContext(ctx context.Context, opts ProvisionOptions, targets ...string) error
+	Status(targets ...string) (statusList []MachineStatus, err error)
+	StatusContext(ctx context.Context, targets ...string) (statusList []MachineStatus, err error)
+	GlobalStatus(prune bool) (statusList []GlobalMachineStatus, err error)
+	GlobalStatusContext(ctx context.Context, prune bool) (statusList []GlobalMachineStatus, err error)
 	Version() (string, error)
+	VersionContext(ctx context.Context) (string, error)
 	SSH(nameOrID, command string) (cmdOutput string, err error)
+	SSHContext(ctx context.Context, nameOrID, command string) (cmdOutput string, err error)
+	SSHCommand(nameOrID, command string) (result SSHResult, err error)
+	SSHCommandContext(ctx context.Context, nameOrID, command string) (result SSHResult, err error)
+	SSHWithInput(nameOrID, command string, input io.Reader) (result SSHResult, err error)
+	SSHWithInputContext(ctx context.Context, nameOrID, command string, input io.Reader) (result SSHResult, err error)
+	SSHConfig(targets ...string) (configs []SSHConfig, err error)
+	SSHConfigContext(ctx context.Context, targets ...string) (configs []SSHConfig, err error)
+	Upload(nameOrID, src, dst string, compress bool) error
+	UploadContext(ctx context.Context, nameOrID, src, dst string, compress bool) error
+	Download(nameOrID, src, dst string) error
+	DownloadContext(ctx context.Context, nameOrID, src, dst string) error
+	Port(nameOrID string) (ports []ForwardedPort, err error)
+	PortContext(ctx context.Context, nameOrID string) (ports []ForwardedPort, err error)
 	PluginList() (plugins []Plugin, err error)
+	PluginListContext(ctx context.Context) (plugins []Plugin, err error)
 	PluginInstall(plugin Plugin) error
+	PluginInstallContext(ctx context.Context, plugin Plugin) error
+	PluginUninstall(plugin Plugin) error
+	PluginUninstallContext(ctx context.Context, plugin Plugin) error
+	PluginUpdate(plugin Plugin) error
+	PluginUpdateContext(ctx context.Context, plugin Plugin) error
+	PluginExpunge(location string) error
+	PluginExpungeContext(ctx context.Context, location string) error
+	PluginRepair(location string) error
+	PluginRepairContext(ctx context.Context, location string) error
+	PluginLicense(plugin Plugin, licenseFile string) error
+	PluginLicenseContext(ctx context.Context, plugin Plugin, licenseFile string) error
+	SnapshotSave(name, nameOrID string) error
+	SnapshotSaveContext(ctx context.Context, name, nameOrID string) error
+	SnapshotRestore(name string, opts SnapshotRestoreOptions, nameOrID string) error
+	SnapshotRestoreContext(ctx context.Context, name string, opts SnapshotRestoreOptions, nameOrID string) error
+	SnapshotPush(targets ...string) error
+	SnapshotPushContext(ctx context.Context, targets ...string) error
+	SnapshotPop(opts SnapshotRestoreOptions, targets ...string) error
+	SnapshotPopContext(ctx context.Context, opts SnapshotRestoreOptions, targets ...string) error
+	SnapshotList(targets ...string) (snapshots []Snapshot, err error)
+	SnapshotListContext(ctx context.Context, targets ...string) (snapshots []Snapshot, err error)
+	SnapshotDelete(name, nameOrID string) error
+	SnapshotDeleteContext(ctx context.Context, name, nameOrID string) error
+	BoxList() (boxes []Box, err error)
+	BoxListContext(ctx context.Context) (boxes []Box, err error)
+	BoxAdd(box Box) error
+	BoxAddContext(ctx context.Context, box Box) error
+	BoxRemove(box Box) error
+	BoxRemoveContext(ctx context.Context, box Box) error
+	BoxUpdate(box Box) error
+	BoxUpdateContext(ctx context.Context, box Box) error
+	BoxOutdated() (boxes []OutdatedBox, err error)
+	BoxOutdatedContext(ctx context.Context) (boxes []OutdatedBox, err error)
+	BoxPrune() error
+	BoxPruneContext(ctx context.Context) error
 
 	// helper functions
 
 	IsPluginInstalled(plugin Plugin) (installed bool, err error)
+	IsPluginInstalledContext(ctx context.Context, plugin Plugin) (installed bool, err error)
+	EnsurePlugins(plugins []Plugin, removeExtras bool) (report PluginReport, err error)
+	EnsurePluginsContext(ctx context.Context, plugins []Plugin, removeExtras bool) (report PluginReport, err error)
+	IsBoxInstalled(box Box) (installed bool, err error)
+	IsBoxInstalledContext(ctx context.Context, box Box) (installed bool, err error)
+	HostPortFor(nameOrID string, guestPort int) (hostPort int, err error)
+	HostPortForContext(ctx context.Context, nameOrID string, guestPort int) (hostPort int, err error)
+	EnsureRunning(targets ...string) (actions []EnsureAction, err error)
+	EnsureRunningContext(ctx context.Context, targets ...string) (actions []EnsureAction, err error)
+	EnsureStopped(targets ...string) (actions []EnsureAction, err error)
+	EnsureStoppedContext(ctx context.Context, targets ...string) (actions []EnsureAction, err error)
+	EnsureAbsent(targets ...string) (actions []EnsureAction, err error)
+	EnsureAbsentContext(ctx context.Context, targets ...string) (actions []EnsureAction, err error)
+	WaitForState(ctx context.Context, nameOrID string, desired ...MachineState) (status MachineStatus, err error)
+	Watch(ctx context.Context, interval time.Duration, targets ...string) <-chan StateChange
+
+	// configuration functions
+
+	WithOutput(streams command.Streams) Vagrant
+	WithEventHandler(handler EventHandler) Vagrant
 }
 
+// systemPluginLocation is the location of plugins bundled with the vagrant installation.
+const systemPluginLocation = "system"
+
 // Plugin encapsulates Vagrant plugin metadata.
 type Plugin struct {
 	Name     string
@@ -36,55 +138,304 @@ type Plugin struct {
 	Location string
 }
 
+// UpOptions customizes how machines are brought up. The zero value uses Vagrant's defaults.
+type UpOptions struct {
+	// Provider is the name of the provider used to create the machines, e.g. "virtualbox" or "libvirt".
+	Provider string
+	// Provision forces the provisioners to run, even on machines that were already provisioned.
+	Provision bool
+	// NoProvision prevents any provisioner from running.
+	NoProvision bool
+	// ProvisionWith limits provisioning to the named provisioners. It implies Provision.
+	ProvisionWith []string
+	// Parallel controls whether machines are brought up in parallel when the provider supports it.
+	Parallel *bool
+	// DestroyOnError controls whether newly created machines are destroyed when a fatal error occurs.
+	DestroyOnError *bool
+	// InstallProvider controls whether Vagrant installs the provider when it is missing.
+	InstallProvider *bool
+}
+
+// providerNameValidator matches valid provider names.
+var providerNameValidator = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// args validates the options and returns their command-line flags.
+func (o UpOptions) args() ([]string, error) {
+	var args []string
+	if len(o.Provider) > 0 {
+		if !providerNameValidator.MatchString(o.Provider) {
+			return nil, fmt.Errorf("invalid provider name: %q", o.Provider)
+		}
+		args = append(args, "--provider", o.Provider)
+	}
+
+	provisionArgs, err := provisionFlags(o.Provision, o.NoProvision, o.ProvisionWith)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, provisionArgs...)
+
+	args = appendToggle(args, "parallel", o.Parallel)
+	args = appendToggle(args, "destroy-on-error", o.DestroyOnError)
+	args = appendToggle(args, "install-provider", o.InstallProvider)
+	return args, nil
+}
+
+// Bool returns a pointer to the given value. It is meant for setting the optional toggles in UpOptions.
+func Bool(v bool) *bool {
+	return &v
+}
+
+// ReloadOptions controls whether provisioners run when machines are reloaded. By default, provisioners only run if
+// they are configured to run always.
+type ReloadOptions struct {
+	// Provision forces the provisioners to run.
+	Provision bool
+	// NoProvision prevents any provisioner from running.
+	NoProvision bool
+	// ProvisionWith limits provisioning to the named provisioners. It implies Provision.
+	ProvisionWith []string
+}
+
+// ProvisionOptions controls which provisioners run when machines are provisioned.
+type ProvisionOptions struct {
+	// ProvisionWith limits provisioning to the named provisioners. All provisioners run when it is empty.
+	ProvisionWith []string
+}
+
+// PluginReport describes the changes made by Vagrant.EnsurePlugins.
+type PluginReport struct {
+	Installed   []Plugin
+	Reinstalled []Plugin
+	Removed     []Plugin
+	Unchanged   []Plugin
+}
+
+// Changed returns true if any plugin was installed, reinstalled or removed.
+func (r PluginReport) Changed() bool {
+	return len(r.Installed) > 0 || len(r.Reinstalled) > 0 || len(r.Removed) > 0
+}
+
+// SSHResult contains the outcome of a command executed on a machine via SSH.
+type SSHResult struct {
+	Stdout string
+	Stderr string
+	// ExitCode is the exit status of the command on the guest.
+	ExitCode int
+	// Duration is the time it took for the command to complete, including the time needed to connect.
+	Duration time.Duration
+}
+
+// sshConnectionFailedStatus is the exit status used by OpenSSH when it cannot connect to a machine.
+const sshConnectionFailedStatus = 255
+
 // wrapper is the default implementation of the Vagrant Interface.
 type wrapper struct {
-	executable string
-	runner     command.Runner
-	logger     log.FieldLogger
+	executable   string
+	runner       command.Runner
+	logger       log.FieldLogger
+	output       command.Streams
+	events       EventHandler
+	timeout      time.Duration
+	pollInterval time.Duration
+	dryRun       *DryRun
+	// machines are the machines targeted by the command being planned in dry-run mode, see planTargets.
+	machines []string
 }
 
-// New creates a new Vagrant CLI wrapper targeting a directory where a Vagrantfile should exist.
+// New creates a new Vagrant CLI wrapper targeting a directory where a Vagrantfile should exist. It panics when the
+// directory is empty. Use NewWithOptions for more control over the wrapper.
 func New(vagrantfileDir string, debug bool) Vagrant {
 	if len(vagrantfileDir) == 0 {
 		panic("vagrantfile dir cannot be empty")
 	}
-	runner := command.ShellRunner{
-		Dir: vagrantfileDir,
-	}
 
 	logger := log.New()
 	if debug {
 		logger.SetLevel(log.DebugLevel)
 	}
 
-	return wrapper{
-		executable: binary,
-		logger:     logger,
-		runner:     runner,
+	v, err := NewWithOptions(vagrantfileDir, WithLogger(logger))
+	if err != nil {
+		panic(err)
 	}
+	return v
 }
 
 // Up creates and configures guest machines according to your Vagrantfile.
-func (w wrapper) Up() error {
+func (w wrapper) Up(targets ...string) error {
+	return w.UpContext(context.Background(), targets...)
+}
+
+// UpContext is like Up but includes a context.
+func (w wrapper) UpContext(ctx context.Context, targets ...string) error {
+	return w.UpWithOptionsContext(ctx, UpOptions{}, targets...)
+}
+
+// UpWithOptions is like Up but allows customizing the provider, provisioning and error handling. The options are
+// validated before anything is run.
+func (w wrapper) UpWithOptions(opts UpOptions, targets ...string) error {
+	return w.UpWithOptionsContext(context.Background(), opts, targets...)
+}
+
+// UpWithOptionsContext is like UpWithOptions but includes a context.
+func (w wrapper) UpWithOptionsContext(ctx context.Context, opts UpOptions, targets ...string) error {
+	optArgs, err := opts.args()
+	if err != nil {
+		return err
+	}
+	cmdArgs := append([]string{"up", "--machine-readable"}, optArgs...)
+
+	if cmdArgs, err = appendTargets(cmdArgs, targets); err != nil {
+		return err
+	}
+	if w, err = w.planTargets(ctx, targets); err != nil {
+		return err
+	}
+
 	w.logger.Info("Starting vagrant environment")
-	return w.execLogOutput("up")
+	return w.execEvents(ctx, cmdArgs...)
 }
 
 // Halt will gracefully shut down the guest operating system and power down the guest machine.
-func (w wrapper) Halt() error {
+func (w wrapper) Halt(targets ...string) error {
+	return w.HaltContext(context.Background(), targets...)
+}
+
+// HaltContext is like Halt but includes a context.
+func (w wrapper) HaltContext(ctx context.Context, targets ...string) error {
+	cmdArgs, err := appendTargets([]string{"halt", "--machine-readable"}, targets)
+	if err != nil {
+		return err
+	}
+	if w, err = w.planTargets(ctx, targets); err != nil {
+		return err
+	}
+
 	w.logger.Info("Stopping vagrant machines")
-	return w.execLogOutput("halt")
+	return w.execEvents(ctx, cmdArgs...)
 }
 
 // Destroy stops the running guest machines and destroys all of the resources created during the creation process.
-func (w wrapper) Destroy() error {
+func (w wrapper) Destroy(targets ...string) error {
+	return w.DestroyContext(context.Background(), targets...)
+}
+
+// DestroyContext is like Destroy but includes a context.
+func (w wrapper) DestroyContext(ctx context.Context, targets ...string) error {
+	cmdArgs, err := appendTargets([]string{"destroy", "--force", "--machine-readable"}, targets)
+	if err != nil {
+		return err
+	}
+	if w, err = w.planTargets(ctx, targets); err != nil {
+		return err
+	}
+
 	w.logger.Info("Deleting vagrant machines")
-	return w.execLogOutput("destroy", "--force")
+	return w.execEvents(ctx, cmdArgs...)
+}
+
+// Suspend saves the current running state of the guest machines and stops them.
+func (w wrapper) Suspend(targets ...string) error {
+	return w.SuspendContext(context.Background(), targets...)
+}
+
+// SuspendContext is like Suspend but includes a context.
+func (w wrapper) SuspendContext(ctx context.Context, targets ...string) error {
+	cmdArgs, err := appendTargets([]string{"suspend", "--machine-readable"}, targets)
+	if err != nil {
+		return err
+	}
+	if w, err = w.planTargets(ctx, targets); err != nil {
+		return err
+	}
+
+	w.logger.Info("Suspending vagrant machines")
+	return w.execEvents(ctx, cmdArgs...)
+}
+
+// Resume brings up guest machines that were previously suspended.
+func (w wrapper) Resume(targets ...string) error {
+	return w.ResumeContext(context.Background(), targets...)
+}
+
+// ResumeContext is like Resume but includes a context.
+func (w wrapper) ResumeContext(ctx context.Context, targets ...string) error {
+	cmdArgs, err := appendTargets([]string{"resume", "--machine-readable"}, targets)
+	if err != nil {
+		return err
+	}
+	if w, err = w.planTargets(ctx, targets); err != nil {
+		return err
+	}
+
+	w.logger.Info("Resuming vagrant machines")
+	return w.execEvents(ctx, cmdArgs...)
+}
+
+// Reload halts the guest machines and brings them back up so changes made to the Vagrantfile take effect.
+func (w wrapper) Reload(opts ReloadOptions, targets ...string) error {
+	return w.ReloadContext(context.Background(), opts, targets...)
+}
+
+// ReloadContext is like Reload but includes a context.
+func (w wrapper) ReloadContext(ctx context.Context, opts ReloadOptions, targets ...string) error {
+	provisionArgs, err := provisionFlags(opts.Provision, opts.NoProvision, opts.ProvisionWith)
+	if err != nil {
+		return err
+	}
+	cmdArgs := append([]string{"reload", "--machine-readable"}, provisionArgs...)
+
+	if cmdArgs, err = appendTargets(cmdArgs, targets); err != nil {
+		return err
+	}
+	if w, err = w.planTargets(ctx, targets); err != nil {
+		return err
+	}
+
+	w.logger.Info("Reloading vagrant machines")
+	return w.execEvents(ctx, cmdArgs...)
+}
+
+// Provision runs the provisioners configured for the guest machines without restarting them.
+//
+// Provisioners are not reported individually by Vagrant, so a ProvisionerEvent is derived from the output when each one
+// starts and ends. Register an EventHandler to receive them.
+func (w wrapper) Provision(opts ProvisionOptions, targets ...string) error {
+	return w.ProvisionContext(context.Background(), opts, targets...)
+}
+
+// ProvisionContext is like Provision but includes a context.
+func (w wrapper) ProvisionContext(ctx context.Context, opts ProvisionOptions, targets ...string) error {
+	provisionArgs, err := provisionFlags(false, false, opts.ProvisionWith)
+	if err != nil {
+		return err
+	}
+	cmdArgs := append([]string{"provision", "--machine-readable"}, provisionArgs...)
+
+	if cmdArgs, err = appendTargets(cmdArgs, targets); err != nil {
+		return err
+	}
+	if w, err = w.planTargets(ctx, targets); err != nil {
+		return err
+	}
+
+	w.logger.Info("Provisioning vagrant machines")
+	return w.execEvents(ctx, cmdArgs...)
 }
 
 // Status reports the status of the machines Vagrant is managing.
-func (w wrapper) Status() (statuses []MachineStatus, err error) {
-	out, err := w.exec("status", "--machine-readable")
+func (w wrapper) Status(targets ...string) ([]MachineStatus, error) {
+	return w.StatusContext(context.Background(), targets...)
+}
+
+// StatusContext is like Status but includes a context.
+func (w wrapper) StatusContext(ctx context.Context, targets ...string) (statuses []MachineStatus, err error) {
+	cmdArgs, err := appendTargets([]string{"status", "--machine-readable"}, targets)
+	if err != nil {
+		return
+	}
+	out, err := w.exec(ctx, cmdArgs...)
 	if err != nil {
 		return
 	}
@@ -120,9 +471,62 @@ func (w wrapper) Status() (statuses []MachineStatus, err error) {
 	return statuses, nil
 }
 
+// GlobalStatus reports the status of the machines in every Vagrant environment on the host. The information is cached
+// by Vagrant and may be outdated; invalid entries are removed first when prune is true.
+func (w wrapper) GlobalStatus(prune bool) ([]GlobalMachineStatus, error) {
+	return w.GlobalStatusContext(context.Background(), prune)
+}
+
+// GlobalStatusContext is like GlobalStatus but includes a context.
+func (w wrapper) GlobalStatusContext(ctx context.Context, prune bool) (statuses []GlobalMachineStatus, err error) {
+	cmdArgs := []string{"global-status", "--machine-readable"}
+	if prune {
+		cmdArgs = append(cmdArgs, "--prune")
+	}
+
+	out, err := w.exec(ctx, cmdArgs...)
+	if err != nil {
+		return
+	}
+	machineInfo, err := parseMachineReadable(out)
+	if err != nil {
+		return
+	}
+
+	var status *GlobalMachineStatus
+	for _, entry := range machineInfo {
+		if entry.mType == "machine-id" { // each machine starts with its id since names are not unique across environments
+			statuses = append(statuses, GlobalMachineStatus{
+				MachineStatus: MachineStatus{Name: entry.target},
+				ID:            entry.data[0],
+			})
+			status = &statuses[len(statuses)-1]
+			continue
+		}
+		if status == nil {
+			continue
+		}
+
+		switch entry.mType {
+		case "provider-name":
+			status.Provider = entry.data[0]
+		case "machine-home":
+			status.Directory = unescapeMachineData(entry.data[0])
+		case "state":
+			status.State = ToMachineState(entry.data[0])
+		}
+	}
+	return
+}
+
 // Version displays the current version of Vagrant you have installed.
-func (w wrapper) Version() (version string, err error) {
-	out, err := w.exec("version", "--machine-readable")
+func (w wrapper) Version() (string, error) {
+	return w.VersionContext(context.Background())
+}
+
+// VersionContext is like Version but includes a context.
+func (w wrapper) VersionContext(ctx context.Context) (version string, err error) {
+	out, err := w.exec(ctx, "version", "--machine-readable")
 	if err != nil {
 		return
 	}
@@ -141,18 +545,82 @@ func (w wrapper) Version() (version string, err error) {
 // SSH executes a command on a Vagrant machine via SSH and returns the stdout/stderr output.
 // You can use an empty string as the nameOrID if you only have one VM defined in your Vagrantfile.
 func (w wrapper) SSH(nameOrID, command string) (string, error) {
+	return w.SSHContext(context.Background(), nameOrID, command)
+}
+
+// SSHContext is like SSH but includes a context.
+func (w wrapper) SSHContext(ctx context.Context, nameOrID, command string) (string, error) {
 	cmdArgs := []string{"ssh", "--no-tty", "--command", command}
 	if len(nameOrID) > 0 {
 		cmdArgs = append(cmdArgs, nameOrID)
 	}
 
-	out, err := w.exec(cmdArgs...)
+	out, err := w.exec(ctx, cmdArgs...)
 	return string(out), err
 }
 
+// SSHCommand executes a command on a Vagrant machine via SSH and returns its output and exit code separately. A command
+// that fails on the guest is not an error: its exit status is reported in SSHResult.ExitCode instead. An error is only
+// returned when the command could not be run, e.g. because the machine is not running or cannot be reached. In that
+// case, a VagrantError or a command.ExitError with status 255 is returned along with the output collected so far.
+// You can use an empty string as the nameOrID if you only have one VM defined in your Vagrantfile.
+func (w wrapper) SSHCommand(nameOrID, command string) (SSHResult, error) {
+	return w.SSHCommandContext(context.Background(), nameOrID, command)
+}
+
+// SSHCommandContext is like SSHCommand but includes a context.
+func (w wrapper) SSHCommandContext(ctx context.Context, nameOrID, cmd string) (SSHResult, error) {
+	return w.SSHWithInputContext(ctx, nameOrID, cmd, nil)
+}
+
+// SSHWithInput is like SSHCommand but streams the input to the standard input of the command, e.g. to pipe a tarball
+// into `tar -x` on the guest.
+func (w wrapper) SSHWithInput(nameOrID, command string, input io.Reader) (SSHResult, error) {
+	return w.SSHWithInputContext(context.Background(), nameOrID, command, input)
+}
+
+// SSHWithInputContext is like SSHWithInput but includes a context.
+func (w wrapper) SSHWithInputContext(ctx context.Context, nameOrID, cmd string, input io.Reader) (SSHResult, error) {
+	cmdArgs := []string{"ssh", "--machine-readable", "--no-tty", "--command", cmd}
+	if len(nameOrID) > 0 {
+		cmdArgs = append(cmdArgs, nameOrID)
+	}
+
+	var stdout, stderr bytes.Buffer
+	start := time.Now()
+	err := w.stream(ctx, command.Streams{Stdin: input, Stdout: &stdout, Stderr: &stderr}, cmdArgs...)
+
+	// vagrant reports its own status in machine-readable records before handing over to ssh
+	records, guestOut := splitMachineReadable(stdout.Bytes())
+	result := SSHResult{
+		Stdout:   string(guestOut),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
+	}
+
+	ee, ok := err.(command.ExitError)
+	if !ok {
+		return result, err
+	}
+	if verr, ok := wrapVagrantError(records, ee).(VagrantError); ok {
+		return result, verr
+	}
+
+	result.ExitCode = ee.ExitStatus()
+	if result.ExitCode == sshConnectionFailedStatus {
+		return result, ee
+	}
+	return result, nil
+}
+
 // PluginList returns a list of all installed plugins, their versions and install locations.
-func (w wrapper) PluginList() (plugins []Plugin, err error) {
-	out, err := w.exec("plugin", "list", "--machine-readable")
+func (w wrapper) PluginList() ([]Plugin, error) {
+	return w.PluginListContext(context.Background())
+}
+
+// PluginListContext is like PluginList but includes a context.
+func (w wrapper) PluginListContext(ctx context.Context) (plugins []Plugin, err error) {
+	out, err := w.exec(ctx, "plugin", "list", "--machine-readable")
 	if err != nil {
 		return
 	}
@@ -183,6 +651,11 @@ func (w wrapper) PluginList() (plugins []Plugin, err error) {
 
 // PluginInstall installs a plugin with the given name or file path.
 func (w wrapper) PluginInstall(plugin Plugin) error {
+	return w.PluginInstallContext(context.Background(), plugin)
+}
+
+// PluginInstallContext is like PluginInstall but includes a context.
+func (w wrapper) PluginInstallContext(ctx context.Context, plugin Plugin) error {
 	if len(plugin.Name) == 0 {
 		return errors.New("plugin must have a name")
 	}
@@ -196,18 +669,118 @@ func (w wrapper) PluginInstall(plugin Plugin) error {
 	}
 
 	w.logger.Infof("Installing vagrant plugin: %s", plugin.Name)
-	return w.execLogOutput(cmdArgs...)
+	return w.execLogOutput(ctx, cmdArgs...)
+}
+
+// PluginUninstall removes an installed plugin.
+func (w wrapper) PluginUninstall(plugin Plugin) error {
+	return w.PluginUninstallContext(context.Background(), plugin)
+}
+
+// PluginUninstallContext is like PluginUninstall but includes a context.
+func (w wrapper) PluginUninstallContext(ctx context.Context, plugin Plugin) error {
+	if len(plugin.Name) == 0 {
+		return errors.New("plugin must have a name")
+	}
+	cmdArgs := []string{"plugin", "uninstall", plugin.Name}
+
+	if plugin.Location == "local" {
+		cmdArgs = append(cmdArgs, "--local")
+	}
+
+	w.logger.Infof("Uninstalling vagrant plugin: %s", plugin.Name)
+	return w.execLogOutput(ctx, cmdArgs...)
+}
+
+// PluginUpdate updates a plugin to the latest version allowed by its version constraint. All plugins in the plugin's
+// location are updated when it has no name.
+func (w wrapper) PluginUpdate(plugin Plugin) error {
+	return w.PluginUpdateContext(context.Background(), plugin)
+}
+
+// PluginUpdateContext is like PluginUpdate but includes a context.
+func (w wrapper) PluginUpdateContext(ctx context.Context, plugin Plugin) error {
+	cmdArgs := []string{"plugin", "update"}
+
+	if len(plugin.Name) > 0 {
+		cmdArgs = append(cmdArgs, plugin.Name)
+	}
+	if plugin.Location == "local" {
+		cmdArgs = append(cmdArgs, "--local")
+	}
+
+	w.logger.Info("Updating vagrant plugins")
+	return w.execLogOutput(ctx, cmdArgs...)
+}
+
+// PluginExpunge removes all user installed plugin information from the given location, either "local" or "global".
+// Global plugins are removed when the location is empty.
+func (w wrapper) PluginExpunge(location string) error {
+	return w.PluginExpungeContext(context.Background(), location)
+}
+
+// PluginExpungeContext is like PluginExpunge but includes a context.
+func (w wrapper) PluginExpungeContext(ctx context.Context, location string) error {
+	cmdArgs := []string{"plugin", "expunge", "--force"}
+
+	if location == "local" {
+		cmdArgs = append(cmdArgs, "--local-only")
+	}
+
+	w.logger.Info("Expunging vagrant plugins")
+	return w.execLogOutput(ctx, cmdArgs...)
+}
+
+// PluginRepair attempts to repair the plugins installed in the given location, either "local" or "global". Global
+// plugins are repaired when the location is empty.
+func (w wrapper) PluginRepair(location string) error {
+	return w.PluginRepairContext(context.Background(), location)
+}
+
+// PluginRepairContext is like PluginRepair but includes a context.
+func (w wrapper) PluginRepairContext(ctx context.Context, location string) error {
+	cmdArgs := []string{"plugin", "repair"}
+
+	if location == "local" {
+		cmdArgs = append(cmdArgs, "--local")
+	}
+
+	w.logger.Info("Repairing vagrant plugins")
+	return w.execLogOutput(ctx, cmdArgs...)
+}
+
+// PluginLicense installs a license file for a proprietary plugin.
+func (w wrapper) PluginLicense(plugin Plugin, licenseFile string) error {
+	return w.PluginLicenseContext(context.Background(), plugin, licenseFile)
+}
+
+// PluginLicenseContext is like PluginLicense but includes a context.
+func (w wrapper) PluginLicenseContext(ctx context.Context, plugin Plugin, licenseFile string) error {
+	if len(plugin.Name) == 0 {
+		return errors.New("plugin must have a name")
+	}
+	if len(licenseFile) == 0 {
+		return errors.New("license file cannot be empty")
+	}
+
+	w.logger.Infof("Installing license for vagrant plugin: %s", plugin.Name)
+	return w.execLogOutput(ctx, "plugin", "license", plugin.Name, licenseFile)
 }
 
 // IsPluginInstalled checks if a plugin has already been installed. It will return an error if the plugin arg has no
 // name or the underlying list operation fails.
-func (w wrapper) IsPluginInstalled(plugin Plugin) (installed bool, err error) {
+func (w wrapper) IsPluginInstalled(plugin Plugin) (bool, error) {
+	return w.IsPluginInstalledContext(context.Background(), plugin)
+}
+
+// IsPluginInstalledContext is like IsPluginInstalled but includes a context.
+func (w wrapper) IsPluginInstalledContext(ctx context.Context, plugin Plugin) (installed bool, err error) {
 	if len(plugin.Name) == 0 {
 		err = errors.New("plugin must have a Name")
 		return
 	}
 
-	installedPlugins, err := w.PluginList()
+	installedPlugins, err := w.PluginListContext(ctx)
 	if err != nil {
 		return
 	}
@@ -225,22 +798,338 @@ func (w wrapper) IsPluginInstalled(plugin Plugin) (installed bool, err error) {
 	return
 }
 
+// EnsurePlugins converges the installed plugins towards the given list. Missing plugins are installed and plugins whose
+// version or location differ from the desired one are reinstalled, after removing them from their current location.
+// Installed plugins that are not listed are removed when removeExtras is true, except for system plugins which Vagrant
+// cannot uninstall.
+//
+// The returned report describes the changes that were made, including the ones that succeeded before an error occurred.
+func (w wrapper) EnsurePlugins(plugins []Plugin, removeExtras bool) (PluginReport, error) {
+	return w.EnsurePluginsContext(context.Background(), plugins, removeExtras)
+}
+
+// EnsurePluginsContext is like EnsurePlugins but includes a context.
+func (w wrapper) EnsurePluginsContext(ctx context.Context, plugins []Plugin, removeExtras bool) (report PluginReport, err error) {
+	desired := map[string]bool{}
+	for _, p := range plugins {
+		if len(p.Name) == 0 {
+			err = errors.New("plugin must have a name")
+			return
+		}
+		desired[p.Name] = true
+	}
+
+	installedPlugins, err := w.PluginListContext(ctx)
+	if err != nil {
+		return
+	}
+	installed := map[string]Plugin{}
+	for _, p := range installedPlugins {
+		installed[p.Name] = p
+	}
+
+	for _, p := range plugins {
+		current, ok := installed[p.Name]
+		switch {
+		case !ok:
+			if err = w.PluginInstallContext(ctx, p); err != nil {
+				return
+			}
+			report.Installed = append(report.Installed, p)
+		case len(p.Version) > 0 && p.Version != current.Version, len(p.Location) > 0 && p.Location != current.Location:
+			// installing into another location would leave the old copy behind
+			if len(p.Location) > 0 && p.Location != current.Location && current.Location != systemPluginLocation {
+				if err = w.PluginUninstallContext(ctx, current); err != nil {
+					return
+				}
+			}
+			if err = w.PluginInstallContext(ctx, p); err != nil {
+				return
+			}
+			report.Reinstalled = append(report.Reinstalled, p)
+		default:
+			report.Unchanged = append(report.Unchanged, current)
+		}
+	}
+
+	if !removeExtras {
+		return
+	}
+	for _, p := range installedPlugins {
+		if desired[p.Name] || p.Location == systemPluginLocation {
+			continue // vagrant refuses to uninstall plugins bundled with the system
+		}
+		if err = w.PluginUninstallContext(ctx, p); err != nil {
+			return
+		}
+		report.Removed = append(report.Removed, p)
+	}
+	return
+}
+
+// WithOutput returns a copy of the wrapper that copies the output of long-running commands (e.g. Up, Halt, Destroy or
+// PluginInstall) to the given streams as it is produced. Output is still logged line by line.
+//
+// Lifecycle commands run in machine-readable mode, so only the human-readable messages they emit are written to
+// standard output.
+func (w wrapper) WithOutput(streams command.Streams) Vagrant {
+	w.output = streams
+	return w
+}
+
+// WithEventHandler returns a copy of the wrapper that invokes the handler with every event emitted while a lifecycle
+// command (e.g. Up, Halt, Destroy or SnapshotRestore) runs. Events are delivered synchronously in the order Vagrant
+// emits them.
+func (w wrapper) WithEventHandler(handler EventHandler) Vagrant {
+	w.events = handler
+	return w
+}
+
+// TargetPattern converts a regular expression into a target that matches every machine whose name satisfies it.
+func TargetPattern(expr string) string {
+	return fmt.Sprintf("/%s/", expr)
+}
+
+// appendTargets adds targets to a list of command arguments. Empty targets are rejected so that a missing machine name
+// cannot turn into a command that acts on every machine.
+func appendTargets(args []string, targets []string) ([]string, error) {
+	for _, t := range targets {
+		if len(t) == 0 {
+			return nil, errors.New("target cannot be empty")
+		}
+		args = append(args, t)
+	}
+	return args, nil
+}
+
+// appendNameOrID adds the name or ID of a single machine to a list of command arguments unless it is empty.
+func appendNameOrID(args []string, nameOrID string) []string {
+	if len(nameOrID) > 0 {
+		args = append(args, nameOrID)
+	}
+	return args
+}
+
 // exec dispatches vagrant commands via the shell runner.
-func (w wrapper) exec(args ...string) ([]byte, error) {
+func (w wrapper) exec(ctx context.Context, args ...string) ([]byte, error) {
 	fullCmd := fmt.Sprintf("%s %s", w.executable, strings.Join(args, " "))
 
+	if w.skip(w.executable, args) {
+		return nil, nil
+	}
+
+	ctx, cancel := w.commandContext(ctx)
+	defer cancel()
+
 	w.logger.Debugf("Running command [%s]", fullCmd)
-	bs, err := w.runner.Execute(w.executable, args...)
+	bs, err := w.runner.ExecuteContext(ctx, w.executable, args...)
 	w.logger.Debugf("Command output [%s]: %s", fullCmd, bs)
 
+	if err != nil && isMachineReadable(args) {
+		err = wrapVagrantError(bs, err)
+	}
 	return bs, err
 }
 
-// execLogOutput logs the output of the command at an info level instead of returning it.
-func (w wrapper) execLogOutput(args ...string) error {
-	out, err := w.exec(args...)
-	if len(out) > 0 {
-		w.logger.Info(string(out))
+// stream dispatches vagrant commands via the shell runner, copying their output to the given streams.
+func (w wrapper) stream(ctx context.Context, streams command.Streams, args ...string) error {
+	fullCmd := fmt.Sprintf("%s %s", w.executable, strings.Join(args, " "))
+
+	if w.skip(w.executable, args) {
+		return nil
+	}
+
+	ctx, cancel := w.commandContext(ctx)
+	defer cancel()
+
+	w.logger.Debugf("Streaming command [%s]", fullCmd)
+	err := w.runner.Stream(ctx, streams, w.executable, args...)
+	w.logger.Debugf("Command finished [%s]", fullCmd)
+
+	return err
+}
+
+// commandContext applies the configured command timeout to a context.
+func (w wrapper) commandContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if w.timeout > 0 {
+		return context.WithTimeout(ctx, w.timeout)
 	}
+	return ctx, func() {} // keep contexts that are never done, so commands stay in the foreground process group
+}
+
+// execLogOutput logs the output of the command line by line as it runs instead of returning it. Standard output is
+// logged at an info level and standard error at a warn level.
+func (w wrapper) execLogOutput(ctx context.Context, args ...string) error {
+	stdout := command.NewLineWriter(func(line string) { w.logger.Info(line) })
+	stderr := command.NewLineWriter(func(line string) { w.logger.Warn(line) })
+
+	err := w.stream(ctx, command.Streams{
+		Stdout: teeWriter(stdout, w.output.Stdout),
+		Stderr: teeWriter(stderr, w.output.Stderr),
+	}, args...)
+
+	stdout.Flush()
+	stderr.Flush()
 	return err
 }
+
+// execEvents runs a command with machine-readable output and processes every line as an Event as soon as it is emitted.
+// UI events are logged at a level matching their type and written to the output stream. A VagrantError is returned when
+// the command fails after emitting an error-exit event.
+func (w wrapper) execEvents(ctx context.Context, args ...string) error {
+	var exitEvent *ErrorExitEvent
+	var provisioners provisionerTracker
+	stdout := command.NewLineWriter(func(line string) {
+		entry, err := parseMachineReadableLine(line)
+		if err != nil {
+			w.logger.Info(line) // vagrant and its plugins may still print unstructured output
+			w.writeOutput(line)
+			return
+		}
+
+		event := newEvent(entry)
+		ended, started := provisioners.track(event)
+		w.emit(ended...)
+
+		switch e := event.(type) {
+		case UIEvent:
+			w.logUIEvent(e)
+			w.writeOutput(e.Message)
+		case ErrorExitEvent:
+			exitEvent = &e
+		}
+		w.emit(event)
+		w.emit(started...)
+	})
+	stderr := command.NewLineWriter(func(line string) { w.logger.Warn(line) })
+
+	err := w.stream(ctx, command.Streams{
+		Stdout: stdout,
+		Stderr: teeWriter(stderr, w.output.Stderr),
+	}, args...)
+
+	stdout.Flush()
+	stderr.Flush()
+	w.emit(provisioners.finish(time.Now())...)
+
+	if ee, ok := err.(command.ExitError); ok && exitEvent != nil {
+		err = newVagrantError(*exitEvent, ee)
+	}
+	return err
+}
+
+// emit passes events to the event handler, when one is configured.
+func (w wrapper) emit(events ...Event) {
+	if w.events == nil {
+		return
+	}
+	for _, e := range events {
+		w.events(e)
+	}
+}
+
+// logUIEvent logs a UI message at a level matching its type.
+func (w wrapper) logUIEvent(ui UIEvent) {
+	logger := w.logger
+	if len(ui.Target) > 0 {
+		logger = logger.WithField("machine", ui.Target)
+	}
+
+	switch ui.Level {
+	case "error":
+		logger.Error(ui.Message)
+	case "warn":
+		logger.Warn(ui.Message)
+	default:
+		logger.Info(ui.Message)
+	}
+}
+
+// writeOutput writes a single line to the standard output stream, when one is configured.
+func (w wrapper) writeOutput(line string) {
+	if w.output.Stdout != nil {
+		fmt.Fprintln(w.output.Stdout, line)
+	}
+}
+
+// provisionFlags returns the command-line flags that control provisioning, or an error if the settings conflict.
+func provisionFlags(provision, noProvision bool, provisionWith []string) ([]string, error) {
+	switch {
+	case noProvision && (provision || len(provisionWith) > 0):
+		return nil, errors.New("provisioning cannot be both enabled and disabled")
+	case noProvision:
+		return []string{"--no-provision"}, nil
+	case len(provisionWith) > 0:
+		for _, name := range provisionWith {
+			if len(name) == 0 || strings.Contains(name, ",") {
+				return nil, fmt.Errorf("invalid provisioner name: %q", name)
+			}
+		}
+		return []string{"--provision-with", strings.Join(provisionWith, ",")}, nil
+	case provision:
+		return []string{"--provision"}, nil
+	}
+	return nil, nil
+}
+
+// appendToggle adds a --flag or --no-flag argument when the toggle is set.
+func appendToggle(args []string, flag string, toggle *bool) []string {
+	switch {
+	case toggle == nil:
+		return args
+	case *toggle:
+		return append(args, "--"+flag)
+	default:
+		return append(args, "--no-"+flag)
+	}
+}
+
+// isMachineReadable returns true when the command arguments request machine-readable output.
+func isMachineReadable(args []string) bool {
+	for _, arg := range args {
+		if arg == "--machine-readable" {
+			return true
+		}
+	}
+	return false
+}
+
+// vagrantRecordTypes lists the machine-readable record types vagrant prints before handing over to another program, like
+// ssh. Only lines of these types are split from the output, so guest output that happens to look like a record, e.g.
+// CSV with a numeric first column, is left untouched.
+var vagrantRecordTypes = map[string]bool{
+	"ui":         true,
+	"metadata":   true,
+	"error-exit": true,
+}
+
+// splitMachineReadable separates the leading machine-readable records from the remaining output of a command.
+func splitMachineReadable(out []byte) (records, rest []byte) {
+	i := 0
+	for i < len(out) {
+		end := bytes.IndexByte(out[i:], '\n')
+		if end < 0 {
+			end = len(out) - i
+		} else {
+			end++
+		}
+
+		entry, err := parseMachineReadableLine(strings.TrimRight(string(out[i:i+end]), "\r\n"))
+		if err != nil || !vagrantRecordTypes[entry.mType] {
+			break
+		}
+		if _, err := strconv.ParseInt(entry.timestamp, 10, 64); err != nil {
+			break
+		}
+		i += end
+	}
+	return out[:i], out[i:]
+}
+
+// teeWriter duplicates writes to an optional second writer.
+func teeWriter(w io.Writer, other io.Writer) io.Writer {
+	if other == nil {
+		return w
+	}
+	return io.MultiWriter(w, other)
+}
@@ -1,9 +1,13 @@
 package vagrantexec
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"io/ioutil"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dominodatalab/vagrant-exec/command"
 	"github.com/sirupsen/logrus"
@@ -14,6 +18,9 @@ import (
 
 type mockRunner struct {
 	mock.Mock
+
+	// stdin holds the input that was streamed to the last command
+	stdin []byte
 }
 
 func (m *mockRunner) Execute(cmd string, cmdargs ...string) ([]byte, error) {
@@ -24,6 +31,26 @@ func (m *mockRunner) Execute(cmd string, cmdargs ...string) ([]byte, error) {
 	return nil, args.Error(1)
 }
 
+func (m *mockRunner) Stream(ctx context.Context, streams command.Streams, cmd string, cmdargs ...string) error {
+	if streams.Stdin != nil {
+		m.stdin, _ = ioutil.ReadAll(streams.Stdin)
+	}
+
+	args := m.MethodCalled("Execute", cmd, cmdargs)
+	if output, ok := args.Get(0).([]byte); ok && streams.Stdout != nil {
+		streams.Stdout.Write(output)
+	}
+	return args.Error(1)
+}
+
+func (m *mockRunner) ExecuteContext(ctx context.Context, cmd string, cmdargs ...string) ([]byte, error) {
+	args := m.MethodCalled("Execute", cmd, cmdargs)
+	if output, ok := args.Get(0).([]byte); ok {
+		return output, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 // mockedWrapperFn returns a generator func that creates a wrapper with a mocked runnner. The func expects the output
 // and error values that the runner will return when invoked.
 func mockedWrapperFn(runnerArgs []string) func([]byte, error) wrapper {
@@ -31,14 +58,19 @@ func mockedWrapperFn(runnerArgs []string) func([]byte, error) wrapper {
 		runner := new(mockRunner)
 		runner.On("Execute", "vagrant", runnerArgs).Return(out, err)
 
-		logger := logrus.New()
-		logger.Out = ioutil.Discard
+		return mockedWrapper(runner)
+	}
+}
 
-		return wrapper{
-			executable: binary,
-			logger:     logger,
-			runner:     runner,
-		}
+// mockedWrapper creates a wrapper that dispatches commands to the given runner and discards its logs.
+func mockedWrapper(runner command.Runner) wrapper {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+
+	return wrapper{
+		executable: binary,
+		logger:     logger,
+		runner:     runner,
 	}
 }
 
@@ -70,7 +102,7 @@ func TestNew(t *testing.T) {
 }
 
 func TestUp(t *testing.T) {
-	mockUp := mockedWrapperFn([]string{"up"})
+	mockUp := mockedWrapperFn([]string{"up", "--machine-readable"})
 
 	t.Run("success", func(t *testing.T) {
 		w := mockUp([]byte("up output"), nil)
@@ -81,10 +113,141 @@ func TestUp(t *testing.T) {
 		w := mockUp(nil, errors.New("up failed"))
 		assert.Error(t, w.Up())
 	})
+
+	t.Run("targets", func(t *testing.T) {
+		mockUp := mockedWrapperFn([]string{"up", "--machine-readable", "srv-1", "/srv-[23]/"})
+		w := mockUp([]byte("up output"), nil)
+		assert.NoError(t, w.Up("srv-1", TargetPattern("srv-[23]")))
+	})
+
+	t.Run("empty_target", func(t *testing.T) {
+		w := mockedWrapper(new(mockRunner))
+		assert.EqualError(t, w.Up("srv-1", ""), "target cannot be empty")
+	})
+
+	t.Run("vagrant_error", func(t *testing.T) {
+		out, _ := ioutil.ReadFile("testdata/up-error")
+		w := mockUp(out, command.NewExitError("vagrant", 1, "syntax error"))
+
+		err := w.Up()
+		require.Error(t, err)
+
+		var ve VagrantError
+		require.True(t, errors.As(err, &ve))
+		assert.Equal(t, "Vagrant::Errors::VagrantfileSyntaxError", ve.ErrorClass())
+		assert.Contains(t, ve.Message(), "unexpected end-of-input")
+		assert.Equal(t, 1, ve.ExitStatus())
+		assert.Empty(t, ve.Machine())
+
+		var ee command.ExitError
+		assert.True(t, errors.As(err, &ee))
+	})
+
+	t.Run("context", func(t *testing.T) {
+		w := mockUp([]byte("up output"), nil)
+		assert.NoError(t, w.UpContext(context.Background()))
+	})
+
+	t.Run("output", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := mockUp(ioutil.ReadFile("testdata/up")).WithOutput(command.Streams{Stdout: &buf})
+
+		assert.NoError(t, w.Up())
+		assert.Equal(t, "Bringing machine 'srv-1' up with 'virtualbox' provider...\n", strings.SplitAfter(buf.String(), "\n")[0])
+		assert.Contains(t, buf.String(), "hello, world\n")
+		assert.NotContains(t, buf.String(), "action")
+	})
+
+	t.Run("events", func(t *testing.T) {
+		var events []Event
+		w := mockUp(ioutil.ReadFile("testdata/up")).WithEventHandler(func(e Event) { events = append(events, e) })
+
+		require.NoError(t, w.Up())
+		require.Len(t, events, 13)
+		assert.Equal(t, MetadataEvent{
+			EventHeader: EventHeader{Timestamp: time.Unix(1563212501, 0), Target: "srv-1"},
+			Key:         "provider",
+			Value:       "virtualbox",
+		}, events[1])
+		assert.Equal(t, ActionEvent{
+			EventHeader: EventHeader{Timestamp: time.Unix(1563212501, 0), Target: "srv-1"},
+			Action:      "up",
+			Phase:       "start",
+		}, events[2])
+		assert.Equal(t, UIEvent{
+			EventHeader: EventHeader{Timestamp: time.Unix(1563212530, 0), Target: "srv-1"},
+			Level:       "warn",
+			Message:     "Remote connection disconnect. Retrying...",
+		}, events[5])
+		assert.Equal(t, "shell", events[8].(ProvisionerEvent).Provisioner)
+		assert.Equal(t, "start", events[8].(ProvisionerEvent).Phase)
+		assert.Equal(t, "end", events[11].(ProvisionerEvent).Phase)
+		assert.Equal(t, "up", events[12].(ActionEvent).Action)
+		assert.Equal(t, "end", events[12].(ActionEvent).Phase)
+	})
+}
+
+func TestUpWithOptions(t *testing.T) {
+	testcases := []struct {
+		name string
+		opts UpOptions
+		args []string
+	}{
+		{
+			"defaults",
+			UpOptions{},
+			[]string{"up", "--machine-readable", "srv-1"},
+		},
+		{
+			"provider",
+			UpOptions{Provider: "libvirt"},
+			[]string{"up", "--machine-readable", "--provider", "libvirt", "srv-1"},
+		},
+		{
+			"provision_with",
+			UpOptions{ProvisionWith: []string{"shell"}},
+			[]string{"up", "--machine-readable", "--provision-with", "shell", "srv-1"},
+		},
+		{
+			"toggles_enabled",
+			UpOptions{Provision: true, Parallel: Bool(true), DestroyOnError: Bool(true), InstallProvider: Bool(true)},
+			[]string{"up", "--machine-readable", "--provision", "--parallel", "--destroy-on-error", "--install-provider", "srv-1"},
+		},
+		{
+			"toggles_disabled",
+			UpOptions{NoProvision: true, Parallel: Bool(false), DestroyOnError: Bool(false), InstallProvider: Bool(false)},
+			[]string{"up", "--machine-readable", "--no-provision", "--no-parallel", "--no-destroy-on-error", "--no-install-provider", "srv-1"},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := mockedWrapperFn(tc.args)(nil, nil)
+			assert.NoError(t, w.UpWithOptions(tc.opts, "srv-1"))
+		})
+	}
+
+	t.Run("invalid_provider", func(t *testing.T) {
+		w := mockedWrapperFn(nil)(nil, nil)
+
+		err := w.UpWithOptions(UpOptions{Provider: "virtualbox --force"})
+		assert.EqualError(t, err, `invalid provider name: "virtualbox --force"`)
+	})
+
+	t.Run("conflicting_provisioning", func(t *testing.T) {
+		w := mockedWrapperFn(nil)(nil, nil)
+
+		err := w.UpWithOptions(UpOptions{Provision: true, NoProvision: true})
+		assert.EqualError(t, err, "provisioning cannot be both enabled and disabled")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"up", "--machine-readable"})(nil, errors.New("up failed"))
+		assert.Error(t, w.UpWithOptions(UpOptions{}))
+	})
 }
 
 func TestHalt(t *testing.T) {
-	mockHalt := mockedWrapperFn([]string{"halt"})
+	mockHalt := mockedWrapperFn([]string{"halt", "--machine-readable"})
 
 	t.Run("success", func(t *testing.T) {
 		w := mockHalt([]byte("halt output"), nil)
@@ -95,10 +258,21 @@ func TestHalt(t *testing.T) {
 		w := mockHalt(nil, errors.New("halt failed"))
 		assert.Error(t, w.Halt())
 	})
+
+	t.Run("targets", func(t *testing.T) {
+		mockHalt := mockedWrapperFn([]string{"halt", "--machine-readable", "srv-1", "/srv-[23]/"})
+		w := mockHalt([]byte("halt output"), nil)
+		assert.NoError(t, w.Halt("srv-1", TargetPattern("srv-[23]")))
+	})
+
+	t.Run("empty_target", func(t *testing.T) {
+		w := mockedWrapper(new(mockRunner))
+		assert.EqualError(t, w.Halt("srv-1", ""), "target cannot be empty")
+	})
 }
 
 func TestDestroy(t *testing.T) {
-	mockDestroy := mockedWrapperFn([]string{"destroy", "--force"})
+	mockDestroy := mockedWrapperFn([]string{"destroy", "--force", "--machine-readable"})
 
 	t.Run("success", func(t *testing.T) {
 		w := mockDestroy([]byte("destroy output"), nil)
@@ -109,6 +283,157 @@ func TestDestroy(t *testing.T) {
 		w := mockDestroy(nil, errors.New("destroy failed"))
 		assert.Error(t, w.Destroy())
 	})
+
+	t.Run("targets", func(t *testing.T) {
+		mockDestroy := mockedWrapperFn([]string{"destroy", "--force", "--machine-readable", "srv-1", "/srv-[23]/"})
+		w := mockDestroy([]byte("destroy output"), nil)
+		assert.NoError(t, w.Destroy("srv-1", TargetPattern("srv-[23]")))
+	})
+
+	t.Run("empty_target", func(t *testing.T) {
+		w := mockedWrapper(new(mockRunner))
+		assert.EqualError(t, w.Destroy("srv-1", ""), "target cannot be empty")
+	})
+}
+
+func TestSuspend(t *testing.T) {
+	mockSuspend := mockedWrapperFn([]string{"suspend", "--machine-readable"})
+
+	t.Run("success", func(t *testing.T) {
+		w := mockSuspend([]byte("suspend output"), nil)
+		assert.NoError(t, w.Suspend())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockSuspend(nil, errors.New("suspend failed"))
+		assert.Error(t, w.Suspend())
+	})
+
+	t.Run("targets", func(t *testing.T) {
+		mockSuspend := mockedWrapperFn([]string{"suspend", "--machine-readable", "srv-1"})
+		w := mockSuspend([]byte("suspend output"), nil)
+		assert.NoError(t, w.Suspend("srv-1"))
+	})
+}
+
+func TestResume(t *testing.T) {
+	mockResume := mockedWrapperFn([]string{"resume", "--machine-readable"})
+
+	t.Run("success", func(t *testing.T) {
+		w := mockResume([]byte("resume output"), nil)
+		assert.NoError(t, w.Resume())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockResume(nil, errors.New("resume failed"))
+		assert.Error(t, w.Resume())
+	})
+
+	t.Run("targets", func(t *testing.T) {
+		mockResume := mockedWrapperFn([]string{"resume", "--machine-readable", "srv-1"})
+		w := mockResume([]byte("resume output"), nil)
+		assert.NoError(t, w.Resume("srv-1"))
+	})
+}
+
+func TestReload(t *testing.T) {
+	testcases := []struct {
+		name string
+		opts ReloadOptions
+		args []string
+	}{
+		{
+			"defaults",
+			ReloadOptions{},
+			[]string{"reload", "--machine-readable", "srv-1"},
+		},
+		{
+			"provision",
+			ReloadOptions{Provision: true},
+			[]string{"reload", "--machine-readable", "--provision", "srv-1"},
+		},
+		{
+			"no_provision",
+			ReloadOptions{NoProvision: true},
+			[]string{"reload", "--machine-readable", "--no-provision", "srv-1"},
+		},
+		{
+			"provision_with",
+			ReloadOptions{Provision: true, ProvisionWith: []string{"ansible", "shell"}},
+			[]string{"reload", "--machine-readable", "--provision-with", "ansible,shell", "srv-1"},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := mockedWrapperFn(tc.args)(nil, nil)
+			assert.NoError(t, w.Reload(tc.opts, "srv-1"))
+		})
+	}
+
+	t.Run("conflicting_options", func(t *testing.T) {
+		w := mockedWrapperFn(nil)(nil, nil)
+
+		err := w.Reload(ReloadOptions{NoProvision: true, ProvisionWith: []string{"shell"}})
+		assert.EqualError(t, err, "provisioning cannot be both enabled and disabled")
+	})
+
+	t.Run("invalid_provisioner", func(t *testing.T) {
+		w := mockedWrapperFn(nil)(nil, nil)
+
+		err := w.Reload(ReloadOptions{ProvisionWith: []string{"shell,ansible"}})
+		assert.EqualError(t, err, `invalid provisioner name: "shell,ansible"`)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockedWrapperFn([]string{"reload", "--machine-readable"})(nil, errors.New("reload failed"))
+		assert.Error(t, w.Reload(ReloadOptions{}))
+	})
+}
+
+func TestProvision(t *testing.T) {
+	mockProvision := mockedWrapperFn([]string{"provision", "--machine-readable"})
+
+	t.Run("success", func(t *testing.T) {
+		w := mockProvision(ioutil.ReadFile("testdata/provision"))
+		assert.NoError(t, w.Provision(ProvisionOptions{}))
+	})
+
+	t.Run("provision_with", func(t *testing.T) {
+		mockProvision := mockedWrapperFn([]string{"provision", "--machine-readable", "--provision-with", "configure", "srv-1"})
+		w := mockProvision(nil, nil)
+		assert.NoError(t, w.Provision(ProvisionOptions{ProvisionWith: []string{"configure"}}, "srv-1"))
+	})
+
+	t.Run("provisioner_events", func(t *testing.T) {
+		var events []ProvisionerEvent
+		w := mockProvision(ioutil.ReadFile("testdata/provision")).WithEventHandler(func(e Event) {
+			if pe, ok := e.(ProvisionerEvent); ok {
+				events = append(events, pe)
+			}
+		})
+		require.NoError(t, w.Provision(ProvisionOptions{}))
+
+		type run struct{ target, provisioner, kind, phase string }
+		var actual []run
+		for _, e := range events {
+			actual = append(actual, run{e.Target, e.Provisioner, e.Type, e.Phase})
+		}
+		expected := []run{
+			{"srv-1", "shell", "shell", "start"},
+			{"srv-1", "shell", "shell", "end"},
+			{"srv-1", "configure", "ansible_local", "start"},
+			{"srv-1", "configure", "ansible_local", "end"},
+			{"srv-2", "shell", "shell", "start"},
+			{"srv-2", "shell", "shell", "end"},
+		}
+		assert.Equal(t, expected, actual)
+		assert.Equal(t, time.Unix(1563562842, 0), events[1].Timestamp)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockProvision(nil, errors.New("provision failed"))
+		assert.Error(t, w.Provision(ProvisionOptions{}))
+	})
 }
 
 func TestStatus(t *testing.T) {
@@ -151,6 +476,27 @@ func TestStatus(t *testing.T) {
 		assert.ElementsMatch(t, expected, statuses)
 	})
 
+	t.Run("target", func(t *testing.T) {
+		mockStatus := mockedWrapperFn([]string{"status", "--machine-readable", "srv-1"})
+		w := mockStatus(ioutil.ReadFile("testdata/status-single"))
+
+		statuses, err := w.Status("srv-1")
+		require.NoError(t, err)
+		require.Len(t, statuses, 1)
+		assert.Equal(t, "srv-1", statuses[0].Name)
+	})
+
+	t.Run("vagrant_error", func(t *testing.T) {
+		out := []byte("1562175813,srv-3,error-exit,Vagrant::Errors::MachineNotFound,The machine with the name 'srv-3' was not found configured for\nthis Vagrant environment.")
+		mockStatus := mockedWrapperFn([]string{"status", "--machine-readable", "srv-3"})
+		w := mockStatus(out, command.NewExitError("vagrant", 1, ""))
+
+		_, err := w.Status("srv-3")
+		require.IsType(t, VagrantError{}, err)
+		assert.Equal(t, "srv-3", err.(VagrantError).Machine())
+		assert.Equal(t, "Vagrant::Errors::MachineNotFound", err.(VagrantError).ErrorClass())
+	})
+
 	t.Run("error", func(t *testing.T) {
 		w := mockStatus(nil, errors.New("runner error"))
 
@@ -159,6 +505,56 @@ func TestStatus(t *testing.T) {
 	})
 }
 
+func TestGlobalStatus(t *testing.T) {
+	mockGlobalStatus := mockedWrapperFn([]string{"global-status", "--machine-readable"})
+
+	t.Run("success", func(t *testing.T) {
+		w := mockGlobalStatus(ioutil.ReadFile("testdata/global-status"))
+
+		statuses, err := w.GlobalStatus(false)
+		require.NoError(t, err)
+
+		expected := []GlobalMachineStatus{
+			{
+				MachineStatus: MachineStatus{Name: "srv-1", Provider: "virtualbox", State: Running},
+				ID:            "a1b2c3d",
+				Directory:     "/home/ci/builds/1234",
+			},
+			{
+				MachineStatus: MachineStatus{Name: "srv-1", Provider: "virtualbox", State: Aborted},
+				ID:            "e4f5a6b",
+				Directory:     "/home/ci/builds/1240",
+			},
+			{
+				MachineStatus: MachineStatus{Name: "default", Provider: "libvirt", State: PowerOff},
+				ID:            "9c8d7e6",
+				Directory:     "/home/dev/my,project",
+			},
+		}
+		assert.Equal(t, expected, statuses)
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		mockGlobalStatus := mockedWrapperFn([]string{"global-status", "--machine-readable", "--prune"})
+		w := mockGlobalStatus(ioutil.ReadFile("testdata/global-status-none"))
+
+		statuses, err := w.GlobalStatus(true)
+		require.NoError(t, err)
+		assert.Empty(t, statuses)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockGlobalStatus(nil, errors.New("runner error"))
+
+		_, err := w.GlobalStatus(false)
+		assert.Error(t, err)
+	})
+}
+
+func TestTargetPattern(t *testing.T) {
+	assert.Equal(t, "/srv-\\d+/", TargetPattern(`srv-\d+`))
+}
+
 func TestVersion(t *testing.T) {
 	mockVersion := mockedWrapperFn([]string{"version", "--machine-readable"})
 
@@ -215,6 +611,84 @@ func TestSSH(t *testing.T) {
 	})
 }
 
+func TestSSHCommand(t *testing.T) {
+	sshCmd := "my-command 1 2 3"
+	mockSSHCommand := mockedWrapperFn([]string{"ssh", "--machine-readable", "--no-tty", "--command", sshCmd})
+
+	t.Run("success", func(t *testing.T) {
+		w := mockSSHCommand([]byte("1563212501,srv-1,metadata,provider,virtualbox\ncommand output\n"), nil)
+
+		result, err := w.SSHCommand("", sshCmd)
+		require.NoError(t, err)
+		assert.Equal(t, "command output\n", result.Stdout)
+		assert.Equal(t, 0, result.ExitCode)
+	})
+
+	t.Run("specific_name", func(t *testing.T) {
+		mockSSHCommand := mockedWrapperFn([]string{"ssh", "--machine-readable", "--no-tty", "--command", sshCmd, "my-target"})
+		w := mockSSHCommand([]byte("command output"), nil)
+
+		result, err := w.SSHCommand("my-target", sshCmd)
+		require.NoError(t, err)
+		assert.Equal(t, "command output", result.Stdout)
+	})
+
+	t.Run("csv_output", func(t *testing.T) {
+		w := mockSSHCommand([]byte("1563212501,srv-1,metadata,provider,virtualbox\n1,alice,30,NY\n2,bob,41,SF\nplain\n"), nil)
+
+		result, err := w.SSHCommand("", sshCmd)
+		require.NoError(t, err)
+		assert.Equal(t, "1,alice,30,NY\n2,bob,41,SF\nplain\n", result.Stdout)
+	})
+
+	t.Run("guest_failure", func(t *testing.T) {
+		w := mockSSHCommand([]byte("partial output\n"), command.NewExitError("vagrant", 3, "oops"))
+
+		result, err := w.SSHCommand("", sshCmd)
+		require.NoError(t, err)
+		assert.Equal(t, "partial output\n", result.Stdout)
+		assert.Equal(t, 3, result.ExitCode)
+	})
+
+	t.Run("not_running", func(t *testing.T) {
+		out := "1563212501,srv-1,metadata,provider,virtualbox\n" +
+			"1563212501,srv-1,error-exit,Vagrant::Errors::VMNotRunningError,VM must be running to open SSH connection.\n"
+		w := mockSSHCommand([]byte(out), command.NewExitError("vagrant", 1, ""))
+
+		result, err := w.SSHCommand("", sshCmd)
+		require.IsType(t, VagrantError{}, err)
+		assert.Equal(t, "Vagrant::Errors::VMNotRunningError", err.(VagrantError).ErrorClass())
+		assert.Empty(t, result.Stdout)
+		assert.Equal(t, 0, result.ExitCode)
+	})
+
+	t.Run("connection_failure", func(t *testing.T) {
+		w := mockSSHCommand(nil, command.NewExitError("vagrant", 255, "Connection refused"))
+
+		result, err := w.SSHCommand("", sshCmd)
+		require.IsType(t, command.ExitError{}, err)
+		assert.Equal(t, 255, result.ExitCode)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w := mockSSHCommand(nil, errors.New("runner error"))
+
+		_, err := w.SSHCommand("", sshCmd)
+		assert.EqualError(t, err, "runner error")
+	})
+}
+
+func TestSSHWithInput(t *testing.T) {
+	runner := new(mockRunner)
+	runner.On("Execute", "vagrant", []string{"ssh", "--machine-readable", "--no-tty", "--command", "tar -x", "srv-1"}).
+		Return([]byte("extracted\n"), nil)
+
+	result, err := mockedWrapper(runner).SSHWithInput("srv-1", "tar -x", strings.NewReader("archive"))
+	require.NoError(t, err)
+	assert.Equal(t, "extracted\n", result.Stdout)
+	assert.Equal(t, []byte("archive"), runner.stdin)
+}
+
 func TestPluginList(t *testing.T) {
 	mockPluginList := mockedWrapperFn([]string{"plugin", "list", "--machine-readable"})
 
@@ -297,6 +771,128 @@ func TestPluginInstall(t *testing.T) {
 	})
 }
 
+func TestPluginUninstall(t *testing.T) {
+	mockPluginUninstall := mockedWrapperFn([]string{"plugin", "uninstall", "my-plugin"})
+
+	plugin := Plugin{Name: "my-plugin"}
+	t.Run("success", func(t *testing.T) {
+		wrapper := mockPluginUninstall(nil, nil)
+
+		assert.NoError(t, wrapper.PluginUninstall(plugin))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		wrapper := mockPluginUninstall(nil, errors.New("runner error"))
+
+		assert.Error(t, wrapper.PluginUninstall(plugin))
+	})
+
+	t.Run("no_name", func(t *testing.T) {
+		wrapper := mockPluginUninstall(nil, nil)
+
+		err := wrapper.PluginUninstall(Plugin{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "plugin must have a name")
+	})
+
+	t.Run("local_uninstall", func(t *testing.T) {
+		mockPluginUninstall := mockedWrapperFn([]string{"plugin", "uninstall", "my-plugin", "--local"})
+		plugin := Plugin{Name: "my-plugin", Location: "local"}
+		wrapper := mockPluginUninstall(nil, nil)
+
+		assert.NoError(t, wrapper.PluginUninstall(plugin))
+	})
+}
+
+func TestPluginUpdate(t *testing.T) {
+	mockPluginUpdate := mockedWrapperFn([]string{"plugin", "update"})
+
+	t.Run("all_plugins", func(t *testing.T) {
+		wrapper := mockPluginUpdate(nil, nil)
+
+		assert.NoError(t, wrapper.PluginUpdate(Plugin{}))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		wrapper := mockPluginUpdate(nil, errors.New("runner error"))
+
+		assert.Error(t, wrapper.PluginUpdate(Plugin{}))
+	})
+
+	t.Run("local_plugin", func(t *testing.T) {
+		mockPluginUpdate := mockedWrapperFn([]string{"plugin", "update", "my-plugin", "--local"})
+		plugin := Plugin{Name: "my-plugin", Location: "local"}
+		wrapper := mockPluginUpdate(nil, nil)
+
+		assert.NoError(t, wrapper.PluginUpdate(plugin))
+	})
+}
+
+func TestPluginExpunge(t *testing.T) {
+	testcases := map[string][]string{
+		"":       {"plugin", "expunge", "--force"},
+		"global": {"plugin", "expunge", "--force"},
+		"local":  {"plugin", "expunge", "--force", "--local-only"},
+	}
+	for location, args := range testcases {
+		wrapper := mockedWrapperFn(args)(nil, nil)
+		assert.NoError(t, wrapper.PluginExpunge(location))
+	}
+
+	t.Run("error", func(t *testing.T) {
+		wrapper := mockedWrapperFn([]string{"plugin", "expunge", "--force"})(nil, errors.New("runner error"))
+
+		assert.Error(t, wrapper.PluginExpunge(""))
+	})
+}
+
+func TestPluginRepair(t *testing.T) {
+	testcases := map[string][]string{
+		"":       {"plugin", "repair"},
+		"global": {"plugin", "repair"},
+		"local":  {"plugin", "repair", "--local"},
+	}
+	for location, args := range testcases {
+		wrapper := mockedWrapperFn(args)(nil, nil)
+		assert.NoError(t, wrapper.PluginRepair(location))
+	}
+
+	t.Run("error", func(t *testing.T) {
+		wrapper := mockedWrapperFn([]string{"plugin", "repair"})(nil, errors.New("runner error"))
+
+		assert.Error(t, wrapper.PluginRepair(""))
+	})
+}
+
+func TestPluginLicense(t *testing.T) {
+	mockPluginLicense := mockedWrapperFn([]string{"plugin", "license", "my-plugin", "/path/license.lic"})
+
+	plugin := Plugin{Name: "my-plugin"}
+	t.Run("success", func(t *testing.T) {
+		wrapper := mockPluginLicense(nil, nil)
+
+		assert.NoError(t, wrapper.PluginLicense(plugin, "/path/license.lic"))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		wrapper := mockPluginLicense(nil, errors.New("runner error"))
+
+		assert.Error(t, wrapper.PluginLicense(plugin, "/path/license.lic"))
+	})
+
+	t.Run("no_name", func(t *testing.T) {
+		wrapper := mockPluginLicense(nil, nil)
+
+		assert.EqualError(t, wrapper.PluginLicense(Plugin{}, "/path/license.lic"), "plugin must have a name")
+	})
+
+	t.Run("no_license_file", func(t *testing.T) {
+		wrapper := mockPluginLicense(nil, nil)
+
+		assert.EqualError(t, wrapper.PluginLicense(plugin, ""), "license file cannot be empty")
+	})
+}
+
 func TestIsPluginInstalled(t *testing.T) {
 	mockPluginList := mockedWrapperFn([]string{"plugin", "list", "--machine-readable"})
 	w := mockPluginList(ioutil.ReadFile("testdata/plugin-list"))
@@ -348,3 +944,112 @@ func TestIsPluginInstalled(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestEnsurePlugins(t *testing.T) {
+	listArgs := []string{"plugin", "list", "--machine-readable"}
+	pluginList, _ := ioutil.ReadFile("testdata/plugin-list")
+
+	t.Run("converge", func(t *testing.T) {
+		runner := new(mockRunner)
+		runner.On("Execute", "vagrant", listArgs).Return(pluginList, nil).Once()
+		runner.On("Execute", "vagrant", []string{"plugin", "install", "vagrant-ip-show", "--plugin-version", "0.0.5"}).Return(nil, nil).Once()
+		runner.On("Execute", "vagrant", []string{"plugin", "install", "vagrant-libvirt"}).Return(nil, nil).Once()
+		w := mockedWrapper(runner)
+
+		report, err := w.EnsurePlugins([]Plugin{
+			{Name: "vagrant-disksize", Version: "0.1.3"},
+			{Name: "vagrant-ip-show", Version: "0.0.5"},
+			{Name: "vagrant-libvirt"},
+		}, false)
+		require.NoError(t, err)
+		runner.AssertExpectations(t)
+
+		assert.True(t, report.Changed())
+		assert.Equal(t, []Plugin{{Name: "vagrant-libvirt"}}, report.Installed)
+		assert.Equal(t, []Plugin{{Name: "vagrant-ip-show", Version: "0.0.5"}}, report.Reinstalled)
+		assert.Equal(t, []Plugin{{Name: "vagrant-disksize", Version: "0.1.3", Location: "global"}}, report.Unchanged)
+		assert.Empty(t, report.Removed)
+	})
+
+	t.Run("remove_extras", func(t *testing.T) {
+		runner := new(mockRunner)
+		runner.On("Execute", "vagrant", listArgs).Return(pluginList, nil).Once()
+		runner.On("Execute", "vagrant", []string{"plugin", "uninstall", "vagrant-ip-show"}).Return(nil, nil).Once()
+		w := mockedWrapper(runner)
+
+		report, err := w.EnsurePlugins([]Plugin{{Name: "vagrant-disksize"}}, true)
+		require.NoError(t, err)
+		runner.AssertExpectations(t)
+
+		assert.Equal(t, []Plugin{{Name: "vagrant-ip-show", Version: "0.0.4", Location: "global"}}, report.Removed)
+	})
+
+	t.Run("remove_extras_system", func(t *testing.T) {
+		systemPlugin := "1562938270,,plugin-name,vagrant-share\n" +
+			"1562938270,vagrant-share,plugin-version,1.1.9%!(VAGRANT_COMMA) system\n"
+
+		runner := new(mockRunner)
+		runner.On("Execute", "vagrant", listArgs).Return(append(pluginList, systemPlugin...), nil).Once()
+		runner.On("Execute", "vagrant", []string{"plugin", "uninstall", "vagrant-ip-show"}).Return(nil, nil).Once()
+		w := mockedWrapper(runner)
+
+		report, err := w.EnsurePlugins([]Plugin{{Name: "vagrant-disksize"}}, true)
+		require.NoError(t, err)
+		runner.AssertExpectations(t)
+
+		assert.Equal(t, []Plugin{{Name: "vagrant-ip-show", Version: "0.0.4", Location: "global"}}, report.Removed)
+	})
+
+	t.Run("location_change", func(t *testing.T) {
+		var calls []string
+		record := func(args mock.Arguments) { calls = append(calls, args.Get(1).([]string)[1]) }
+
+		runner := new(mockRunner)
+		runner.On("Execute", "vagrant", listArgs).Return(pluginList, nil).Once()
+		runner.On("Execute", "vagrant", []string{"plugin", "uninstall", "vagrant-ip-show"}).Run(record).Return(nil, nil).Once()
+		runner.On("Execute", "vagrant", []string{"plugin", "install", "vagrant-ip-show", "--local"}).Run(record).Return(nil, nil).Once()
+		w := mockedWrapper(runner)
+
+		report, err := w.EnsurePlugins([]Plugin{{Name: "vagrant-disksize"}, {Name: "vagrant-ip-show", Location: "local"}}, false)
+		require.NoError(t, err)
+		runner.AssertExpectations(t)
+
+		assert.Equal(t, []Plugin{{Name: "vagrant-ip-show", Location: "local"}}, report.Reinstalled)
+		assert.Equal(t, []string{"uninstall", "install"}, calls)
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		w := mockedWrapperFn(listArgs)(pluginList, nil)
+
+		report, err := w.EnsurePlugins([]Plugin{{Name: "vagrant-disksize"}, {Name: "vagrant-ip-show", Location: "global"}}, false)
+		require.NoError(t, err)
+		assert.False(t, report.Changed())
+		assert.Len(t, report.Unchanged, 2)
+	})
+
+	t.Run("partial_failure", func(t *testing.T) {
+		runner := new(mockRunner)
+		runner.On("Execute", "vagrant", listArgs).Return(pluginList, nil).Once()
+		runner.On("Execute", "vagrant", []string{"plugin", "install", "vagrant-libvirt"}).Return(nil, nil).Once()
+		runner.On("Execute", "vagrant", []string{"plugin", "install", "vagrant-hostmanager"}).Return(nil, errors.New("runner error")).Once()
+		w := mockedWrapper(runner)
+
+		report, err := w.EnsurePlugins([]Plugin{{Name: "vagrant-libvirt"}, {Name: "vagrant-hostmanager"}}, false)
+		assert.Error(t, err)
+		assert.Equal(t, []Plugin{{Name: "vagrant-libvirt"}}, report.Installed)
+	})
+
+	t.Run("no_name", func(t *testing.T) {
+		w := mockedWrapperFn(listArgs)(pluginList, nil)
+
+		_, err := w.EnsurePlugins([]Plugin{{Version: "1.0"}}, false)
+		assert.EqualError(t, err, "plugin must have a name")
+	})
+
+	t.Run("list_error", func(t *testing.T) {
+		w := mockedWrapperFn(listArgs)(nil, errors.New("runner error"))
+
+		_, err := w.EnsurePlugins([]Plugin{{Name: "vagrant-disksize"}}, false)
+		assert.Error(t, err)
+	})
+}